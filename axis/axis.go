@@ -2,6 +2,7 @@ package axis
 
 import (
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/tomarus/chart/format"
@@ -16,6 +17,8 @@ type Axis struct {
 	grid     int
 	ticks    int
 	center   bool
+	unit     string
+	labels   image.Labels
 }
 
 // Formatter is the callback interface function used to format a label.
@@ -47,17 +50,31 @@ func New(p Position, f Formatter) *Axis {
 
 // NewSI creates a new Axis on the specified position using the default SI formatter.
 func NewSI(p Position, base int) *Axis {
-	return New(p, func(in float64) string {
+	a := New(p, func(in float64) string {
 		return format.SI(in, 1, float64(base), "", "", "")
 	})
+	a.labels = image.Labels{Format: "si", Base: base}
+	return a
 }
 
 // NewTime creates a new Axis on the specified position using the default Time formatter.
 // A timefmt is specified using the default Go Time format, e.g. 2006-01-02 15:04
 func NewTime(p Position, timefmt string) *Axis {
-	return New(p, func(in float64) string {
+	a := New(p, func(in float64) string {
 		return time.Unix(int64(in), 0).Format(timefmt)
 	})
+	a.labels = image.Labels{Format: "time", Layout: timefmt}
+	return a
+}
+
+// NewFloat creates a new Axis on the specified position which formats values
+// with a fixed number of decimals.
+func NewFloat(p Position, decimals int) *Axis {
+	a := New(p, func(in float64) string {
+		return strconv.FormatFloat(in, 'f', decimals, 64)
+	})
+	a.labels = image.Labels{Format: "float", Decimals: decimals}
+	return a
 }
 
 // Ticks sets the number of gridlines/labels or ticks for this axis.
@@ -90,10 +107,50 @@ func (a *Axis) Center() *Axis {
 	return a
 }
 
+// Unit sets a unit which is appended to all labels, e.g. "B/s", "%" or "ms".
+func (a *Axis) Unit(u string) *Axis {
+	a.unit = u
+	return a
+}
+
+// Format formats a single value like the axis labels, including the unit.
+func (a *Axis) Format(value float64) string {
+	return a.format(value) + a.unit
+}
+
+// Labels returns how the labels are formatted, for images which update the
+// labels themselves. The format is empty for axes with a custom formatter.
+func (a *Axis) Labels() image.Labels {
+	l := a.labels
+	l.Unit = a.unit
+	return l
+}
+
+// pad is the space in pixels between the labels and the chart area.
+const pad = 4
+
+// Margin returns the space in pixels needed outside of the chart area to
+// draw the labels, the width of the widest label for a Left axis or the
+// height of the labels for a Bottom axis.
+func (a *Axis) Margin(img image.Image, h int, min, max float64) int {
+	switch a.position {
+	case Bottom:
+		_, th := img.MeasureText(image.GridRole, a.Format(max))
+		return th + pad
+	case Left:
+		m := 0
+		for _, str := range a.Scales(h, min, max) {
+			if tw, _ := img.MeasureText(image.GridRole, str); tw > m {
+				m = tw
+			}
+		}
+		return m + 2*pad
+	}
+	return 0
+}
+
 // Draw renders the grid and labels.
 // mx/my is the top-left start position, the margin (or offset).
-// FIXME there are text-margin constants in this function which are probably dependent on the font and size used.
-// It also depends a bit too much on the actual font/line/color drawing stuff.
 func (a *Axis) Draw(img image.Image, w, h, mx, my int, min, max float64) {
 	const col = "title2"
 
@@ -124,8 +181,9 @@ func (a *Axis) Draw(img image.Image, w, h, mx, my int, min, max float64) {
 			toff = float64(w) / float64(a.ticks) / 2.
 		}
 		for dx := (float64(w) / float64(a.ticks)) - float64(off); dx < float64(w)+toff; dx += float64(w) / float64(a.ticks) {
-			str := a.format(min + ((max-min)/float64(w))*(float64(dx)-float64(toff)))
-			img.TextID("grid", col, "middle", image.GridRole, int(dx)+mx+off-int(toff), my+h+14, str) // FIXME "14" (padding/offset)
+			str := a.Format(min + ((max-min)/float64(w))*(float64(dx)-float64(toff)))
+			_, th := img.MeasureText(image.GridRole, str)
+			img.TextID("grid", col, "middle", image.GridRole, int(dx)+mx+off-int(toff), my+h+th, str)
 		}
 	case Left:
 		if a.grid > 0 {
@@ -145,7 +203,8 @@ func (a *Axis) Draw(img image.Image, w, h, mx, my int, min, max float64) {
 		for dy := 0; dy <= h; dy += h / a.ticks {
 			str := sc[i]
 			i++
-			img.TextID("ygrid", col, "end", image.GridRole, mx-4, dy+my+4, str) // FIXME "4" (padding/spacing)
+			_, th := img.MeasureText(image.GridRole, str)
+			img.TextID("ygrid", col, "end", image.GridRole, mx-pad, dy+my+th/4, str)
 		}
 	}
 }
@@ -156,7 +215,7 @@ func (a *Axis) Scales(h int, min, max float64) []string {
 	switch a.position {
 	case Left:
 		for dy := 0; dy <= h; dy += h / a.ticks {
-			str := a.format(max - ((max-min)/float64(h))*float64(dy))
+			str := a.Format(max - ((max-min)/float64(h))*float64(dy))
 			s = append(s, str)
 		}
 	}
@@ -0,0 +1,182 @@
+// Package canvas provides an html canvas interface for tomarus chart lib.
+//
+// The chart is written as a self-contained html fragment of two canvas
+// elements and a script which draws the chart. It has the same crosshair,
+// selection, series toggle, moving average and export features as svg
+// charts, but very wide charts with many series don't create a large
+// document. The series are drawn once on the bottom canvas and the
+// crosshair and selection on the top canvas, so moving the mouse doesn't
+// redraw the series.
+package canvas
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"time"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+// Canvas implements the chart interface to write html canvas charts.
+type Canvas struct {
+	w                io.Writer
+	data             data.Collection
+	width, height    int
+	marginx, marginy int
+	start, end       int64
+	canvasw, canvash int
+	pal              *palette.Palette
+	legend           *image.Legend
+	labels           [2]image.Labels // x and y axis labels
+	ops              []interface{}
+	image.TextMetrics
+}
+
+// New initializes a new html canvas chart image writer.
+func New() *Canvas {
+	return &Canvas{
+		TextMetrics: image.NewTextMetrics(),
+	}
+}
+
+// Start initializes a new image and sets the defaults.
+func (cv *Canvas) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *image.Layout) {
+	cv.w = wr
+	cv.data = d
+	cv.width = w
+	cv.height = h
+	cv.marginx = mx
+	cv.marginy = my
+	cv.start = start
+	cv.end = end
+	cv.pal = p
+	cv.legend = l.Legend
+	cv.labels = [2]image.Labels{l.XLabels, l.YLabels}
+	cv.canvasw, cv.canvash = l.Width, l.Height
+	cv.ops = nil
+}
+
+// Graph renders all chart dataset values to the visible chart area. The
+// values are drawn by the script, Graph only marks their place between the
+// other drawing operations.
+func (cv *Canvas) Graph() error {
+	cv.ops = append(cv.ops, []interface{}{"graph"})
+	return nil
+}
+
+// Text writes a string to the image.
+func (cv *Canvas) Text(color, align string, role image.TextRole, x, y int, txt string) {
+	cv.TextID("", color, align, role, x, y, txt)
+}
+
+// TextID writes a string to the image using an id. The labels of the Y
+// axis, with id ygrid, show the scale of the selected series.
+func (cv *Canvas) TextID(id, color, align string, role image.TextRole, x, y int, txt string) {
+	switch align {
+	case "begin":
+		align = "left"
+	case "middle":
+		align = "center"
+	case "end":
+		align = "right"
+	}
+	cv.ops = append(cv.ops, []interface{}{"text", id, color, align, int(role), x, y, txt})
+}
+
+// Line draws a line between the points using the color name from the palette.
+func (cv *Canvas) Line(color string, x1, y1, x2, y2 int) {
+	cv.ops = append(cv.ops, []interface{}{"line", color, x1, y1, x2, y2})
+}
+
+// Legend draws the legend. The color swatches toggle the series.
+func (cv *Canvas) Legend() {
+	l := cv.legend
+	if !l.Visible() {
+		return
+	}
+	for c := 0; c < l.Columns; c++ {
+		cv.Text("title2", "right", image.GridRole, l.Column(c)+l.ColWidth, l.Y+11, l.Header())
+	}
+	for i, d := range cv.data {
+		x, y := l.Entry(i)
+		cv.ops = append(cv.ops, []interface{}{"swatch", i, x, y})
+		cv.Text("title", "left", image.GridRole, x+20, y+11, d.Title)
+		cv.Text("title", "right", image.GridRole, x+l.ColWidth, y+11, l.Stats(i))
+		cv.Line("grid2", x, y+11+3, x+l.ColWidth, y+11+3)
+	}
+}
+
+// Border draws a border around the chart area.
+func (cv *Canvas) Border(x, y, w, h int) {
+	cv.ops = append(cv.ops, []interface{}{"border", x, y, w, h})
+}
+
+// End finishes and writes the html fragment to the output writer.
+func (cv *Canvas) End() error {
+	cv.p(`<div class="chart" style="position:relative;width:%dpx;height:%dpx">`, cv.canvasw, cv.canvash)
+	cv.p(`<canvas style="position:absolute;left:0;top:0;width:%dpx;height:%dpx"></canvas>`, cv.canvasw, cv.canvash)
+	cv.p(`<canvas style="position:absolute;left:0;top:0;width:%dpx;height:%dpx;cursor:crosshair"></canvas>`, cv.canvasw, cv.canvash)
+	cv.p(`<script>`)
+	cv.p(`(function() {`)
+	cv.p("const root=document.currentScript.parentNode")
+	cv.p("const cw=%d,ch=%d,w=%d,h=%d,mx=%d,my=%d", cv.canvasw, cv.canvash, cv.width, cv.height, cv.marginx, cv.marginy)
+	cv.p("const start=%d,end=%d", cv.start*1000, cv.end*1000)
+	jsdata, _ := json.Marshal(cv.data)
+	cv.p("const data=%s", jsdata)
+	cv.p("const raw=%s", image.ScriptValues(cv.data))
+	cv.p("const missing=%d", data.Missing)
+	jsfmt, _ := json.Marshal(cv.labels)
+	zone, offset := time.Unix(cv.end, 0).Zone()
+	cv.p("const [xfmt,yfmt]=%s,tz=%d,tzname=%q", jsfmt, offset, zone)
+	jscolors, _ := json.Marshal(cv.colors())
+	cv.p("const colors=%s", jscolors)
+	axis := make([]string, len(cv.data))
+	for i := range cv.data {
+		axis[i] = cv.pal.GetAxisColorName(i)
+	}
+	jsaxis, _ := json.Marshal(axis)
+	cv.p("const axis=%s", jsaxis)
+	jsfonts, _ := json.Marshal([]string{cv.font(image.TitleRole), cv.font(image.GridRole)})
+	cv.p("const fonts=%s", jsfonts)
+	jsops, _ := json.Marshal(cv.ops)
+	cv.p("const ops=%s", jsops)
+	_, err := fmt.Fprint(cv.w, js)
+	cv.p("})()")
+	cv.p(`</script>`)
+	cv.p(`</div>`)
+	return err
+}
+
+// colors returns the css colors of the palette colors used by the chart.
+func (cv *Canvas) colors() map[string]string {
+	names := []string{"background", "grid", "grid2", "title", "title2", "border", "marker", "select"}
+	for i := range cv.data {
+		names = append(names, cv.pal.GetAxisColorName(i))
+	}
+	m := make(map[string]string, len(names))
+	for _, n := range names {
+		c := color.NRGBAModel.Convert(cv.pal.GetColor(n)).(color.NRGBA)
+		m[n] = fmt.Sprintf("rgba(%d,%d,%d,%.3g)", c.R, c.G, c.B, float64(c.A)/255)
+	}
+	return m
+}
+
+// font returns the css font of role, the title is written in small caps
+// like svg charts.
+func (cv *Canvas) font(role image.TextRole) string {
+	f := cv.Fonts[role]
+	style := ""
+	if role == image.TitleRole {
+		style = "italic small-caps "
+	}
+	family, weight := f.CSS()
+	return fmt.Sprintf("%s%s %gpx %s", style, weight, f.Size, family)
+}
+
+func (cv *Canvas) p(format string, a ...interface{}) (n int, err error) {
+	return fmt.Fprintf(cv.w, format+"\n", a...)
+}
@@ -0,0 +1,61 @@
+package canvas
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+func TestEnd(t *testing.T) {
+	var out bytes.Buffer
+	p, _ := palette.NewPalette("white")
+	d := data.Collection{data.NewData(&data.Options{Title: "</script>"}, []float64{1, math.NaN(), 3})}
+	d.Normalize(10)
+	l := &image.Layout{Width: 120, Height: 60, Legend: &image.Legend{Position: image.LegendHidden},
+		YLabels: image.Labels{Format: "si", Base: 1024, Unit: "B"}}
+
+	cv := New()
+	cv.Start(&out, 100, 10, 10, 20, 0, 60, p, d, l)
+	cv.Graph()
+	cv.TextID("ygrid", "title", "end", image.GridRole, 8, 12, "3.00")
+	cv.Line("grid", 10, 20, 110, 20)
+	cv.Border(9, 19, 101, 11)
+	if err := cv.End(); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+
+	if n := strings.Count(html, "</script>"); n != 1 {
+		t.Errorf("Expected the script to be closed once, got %d", n)
+	}
+	if !strings.Contains(html, "const raw=[[1,null,3]]") {
+		t.Error("Expected NaN values written as null")
+	}
+	if !strings.Contains(html, `const [xfmt,yfmt]=[{"format":""},{"format":"si","base":1024,"unit":"B"}],tz=`) {
+		t.Error("Expected the axis label formats")
+	}
+	m := regexp.MustCompile(`(?m)^const ops=(.*)$`).FindStringSubmatch(html)
+	if m == nil {
+		t.Fatal("Expected drawing operations")
+	}
+	var ops [][]interface{}
+	if err := json.Unmarshal([]byte(m[1]), &ops); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"graph", "text", "line", "border"}
+	for i, op := range ops {
+		if op[0] != expect[i] {
+			t.Errorf("Expected %s operation, got %v", expect[i], op[0])
+		}
+	}
+	if ops[1][1] != "ygrid" || ops[1][3] != "right" {
+		t.Errorf("Expected right aligned ygrid text, got %v", ops[1])
+	}
+}
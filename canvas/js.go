@@ -0,0 +1,297 @@
+package canvas
+
+import "github.com/tomarus/chart/image"
+
+const js = image.Script + `
+let active, vis = false, mk = {x: 0, y: 0}, mk2 = {x: 0, y: 0}, loc = {x: 0, y: 0}, selx, sely, seltxt = "", mavtxt = "", mav = 0, selmode = 0
+const dpr = window.devicePixelRatio || 1
+const base = root.children[0], overlay = root.children[1]
+const bctx = context(base), octx = context(overlay)
+const buttons = []
+init()
+function context(c) {
+	c.width = Math.round(cw*dpr)
+	c.height = Math.round(ch*dpr)
+	let ctx = c.getContext('2d')
+	ctx.setTransform(dpr, 0, 0, dpr, 0, 0)
+	return ctx
+}
+function init() {
+	ops.forEach(op => {
+		if (op[0] === 'swatch') buttons.push({x: op[2], y: op[3], w: 12, h: 12, f: () => click(op[1])})
+	})
+	let y = h + my + 4
+	buttons.push({x: w+mx-12, y: y, w: 12, h: 12, f: maclick})
+	bctx.font = fonts[1]
+	let tw = bctx.measureText('csv').width, jw = bctx.measureText('json').width
+	buttons.push({x: w+mx-20-tw, y: y, w: tw, h: 12, f: csv, export: true})
+	buttons.push({x: w+mx-52-jw, y: y, w: jw, h: 12, f: json, export: true})
+	draw()
+	status()
+	handlemouse()
+}
+function handlemouse() {
+	overlay.addEventListener('mousedown', function(evt) {
+		let b = button(position(evt))
+		if (b) {
+			if (!b.export) selmode = 0
+			b.f()
+			evt.preventDefault()
+			return
+		}
+		selmode++
+		if (selmode > 2) {
+			selmode = 0
+			markerpos(evt)
+		}
+		selx = loc.x
+		sely = loc.y
+		evt.preventDefault()
+	})
+	overlay.addEventListener('mouseup', function(evt) {
+		if (selmode == 1) selmode = 2
+		evt.preventDefault()
+	})
+	overlay.addEventListener('mousemove', function(evt) {
+		markerpos(evt)
+		overlay.style.cursor = button(loc) ? 'pointer' : 'crosshair'
+	})
+	overlay.addEventListener('mouseleave', function(evt) {
+		marker(false, 0, 0)
+		status()
+	})
+}
+function position(evt) {
+	let r = overlay.getBoundingClientRect()
+	return {x: (evt.clientX - r.left) * cw / r.width, y: (evt.clientY - r.top) * ch / r.height}
+}
+function button(p) {
+	return buttons.find(b => p.x >= b.x && p.x < b.x+b.w && p.y >= b.y && p.y < b.y+b.h)
+}
+function markerpos(evt) {
+	loc = position(evt)
+	if (loc.x<mx || loc.x>=w+mx || loc.y<my || loc.y>=h+my) {
+		marker(false, 0, 0)
+	} else {
+		marker(true, Math.floor(loc.x), Math.floor(loc.y))
+	}
+	status()
+	evt.preventDefault()
+}
+function marker(v, x, y) {
+	if (selmode == 2) {
+		return
+	}
+	vis = v
+	if (!v) {
+		seltxt = ""
+		return
+	}
+	let dx, dy
+	if (selmode) {
+		mk2 = {x: x, y: y}
+		dx = Math.abs(selx-x)
+		dy = Math.abs(sely-y)
+	} else {
+		mk = {x: x, y: y}
+		selx = w
+	}
+	let px = x-mx
+	let py = y-my
+	let n = active||0
+	let v1 = data[n].fmax - data[n].fmax / h * py
+	let d
+	if (selx > 0 && selx != w) {
+		d = new Date((end - start) / w * Math.min(px, selx-mx||0) + start)
+	} else {
+		d = new Date(((end-start) / w * px) + start)
+	}
+	seltxt = d.toLocaleTimeString('nl-NL', dopt)
+	if (selmode) {
+		let v2 = data[n].fmax / h * dy
+		let t = (end-start) / w * dx / 1000
+		seltxt += ' Len: ' + fmtime(t) + ' Delta-Y: ' + fmtu(Math.abs(v2), data[n])
+	} else {
+		seltxt += ' Y:' + fmtu(v1, data[n])
+	}
+}
+function status() {
+	let ctx = octx
+	ctx.clearRect(0, 0, cw, ch)
+	if (vis) {
+		ctx.strokeStyle = colors.marker
+		ctx.lineWidth = 1
+		ctx.beginPath()
+		cross(ctx, mk)
+		if (selmode) cross(ctx, mk2)
+		ctx.stroke()
+		if (selmode) {
+			ctx.globalAlpha = .25
+			ctx.fillStyle = colors.select
+			ctx.fillRect(Math.min(selx, mk2.x), Math.min(sely, mk2.y), Math.abs(selx-mk2.x), Math.abs(sely-mk2.y))
+			ctx.globalAlpha = 1
+		}
+	}
+	text(ctx, 'title', 'left', 1, mx, my/2+4, seltxt + (mavtxt !== "" ? " " + mavtxt : ""))
+}
+function cross(ctx, p) {
+	ctx.moveTo(p.x+.5, my)
+	ctx.lineTo(p.x+.5, my+h)
+	ctx.moveTo(mx, p.y+.5)
+	ctx.lineTo(mx+w, p.y+.5)
+}
+function draw() {
+	let ctx = bctx
+	ctx.clearRect(0, 0, cw, ch)
+	ctx.fillStyle = colors.background
+	ctx.fillRect(0, 0, cw, ch)
+	let yi = 0
+	ops.forEach(op => {
+		switch (op[0]) {
+		case 'graph':
+			graph(ctx)
+			break
+		case 'text': {
+			let txt = op[7]
+			if (op[1] === 'ygrid') {
+				let s = data[active||0].scale || []
+				if (s[yi] !== undefined) txt = s[yi]
+				yi++
+			}
+			text(ctx, op[2], op[3], op[4], op[5], op[6], txt)
+			break
+		}
+		case 'line':
+			line(ctx, op[1], op[2], op[3], op[4], op[5])
+			break
+		case 'swatch':
+			ctx.globalAlpha = active === undefined || active === op[1] ? 1 : .2
+			ctx.fillStyle = colors[axis[op[1]]]
+			ctx.fillRect(op[2], op[3], 12, 12)
+			ctx.globalAlpha = 1
+			break
+		case 'border':
+			ctx.globalAlpha = .666
+			ctx.strokeStyle = colors.border
+			ctx.lineWidth = 1
+			ctx.strokeRect(op[1]+.5, op[2]+.5, op[3], op[4])
+			ctx.globalAlpha = 1
+			break
+		}
+	})
+	// the moving average button
+	ctx.fillStyle = colors.marker
+	ctx.fillRect(w+mx-12, h+my+4, 12, 12)
+	text(ctx, 'title2', 'right', 1, w+mx-20, h+my+15, 'csv')
+	text(ctx, 'title2', 'right', 1, w+mx-52, h+my+15, 'json')
+}
+function text(ctx, c, align, role, x, y, txt) {
+	ctx.font = fonts[role]
+	ctx.textAlign = align || 'left'
+	ctx.globalAlpha = c === 'title' || c === 'title2' ? .75 : 1
+	ctx.fillStyle = colors[c] || colors.title
+	ctx.fillText(txt, x, y)
+	ctx.globalAlpha = 1
+}
+function line(ctx, c, x1, y1, x2, y2) {
+	ctx.save()
+	ctx.strokeStyle = colors[c] || colors.grid
+	ctx.lineWidth = c === 'grid' ? .75 : c === 'grid2' ? .33 : 1
+	if (c === 'grid' || c === 'grid2') ctx.setLineDash([1, 1])
+	ctx.beginPath()
+	ctx.moveTo(x1+.5, y1+.5)
+	ctx.lineTo(x2+.5, y2+.5)
+	ctx.stroke()
+	ctx.restore()
+}
+function graph(ctx) {
+	ctx.save()
+	ctx.translate(mx, my)
+	let n = active||0
+	data.forEach((d, i) => {
+		if (active !== undefined && active !== i) return
+		let k = (i === n ? h : d.max) / h
+		ctx.fillStyle = colors[axis[i]]
+		if (d.high) {
+			envelope(ctx, d, k)
+			polyline(ctx, d.values, k, colors[axis[i]], 1)
+		} else {
+			columns(ctx, d.values, k)
+		}
+		polyline(ctx, d.smoothed || [], k, colors.marker, 2)
+	})
+	if (mav > 0) {
+		polyline(ctx, topixels(smooth(raw[n], smoothing(n)), data[n].fmax), 1, colors.marker, 2)
+	}
+	ctx.restore()
+}
+// columns fills the area below the values as a single path, which is much
+// faster than drawing a line for each pixel.
+function columns(ctx, values, k) {
+	let pen = false, n = Math.min(w, values.length)
+	ctx.beginPath()
+	for (let x=0; x<n; x++) {
+		if (values[x] === missing) {
+			if (pen) ctx.lineTo(x, h)
+			pen = false
+			continue
+		}
+		let y = h - values[x]*k
+		if (!pen) ctx.moveTo(x, h)
+		ctx.lineTo(x, y)
+		ctx.lineTo(x+1, y)
+		pen = true
+	}
+	if (pen) ctx.lineTo(n, h)
+	ctx.fill()
+}
+function envelope(ctx, d, k) {
+	ctx.globalAlpha = .35
+	ctx.beginPath()
+	for (let x=0; x<Math.min(w, d.low.length); x++) {
+		if (d.low[x] === missing) continue
+		let lo = d.low[x]*k, hi = d.high[x]*k
+		ctx.rect(x, h-hi, 1, Math.max(1, hi-lo))
+	}
+	ctx.fill()
+	ctx.globalAlpha = 1
+}
+function polyline(ctx, values, k, c, lw) {
+	let pen = false
+	ctx.beginPath()
+	for (let x=0; x<Math.min(w, values.length); x++) {
+		if (values[x] === missing) {
+			pen = false
+			continue
+		}
+		let y = h - values[x]*k
+		if (pen) ctx.lineTo(x, y)
+		else ctx.moveTo(x, y)
+		pen = true
+	}
+	ctx.strokeStyle = c
+	ctx.lineWidth = lw
+	ctx.stroke()
+}
+function click(n) {
+	active = active === n ? undefined : n
+	draw()
+	status()
+}
+// ma redraws the chart, the moving average is drawn with the series.
+function ma(n) {
+	draw()
+}
+function exportrange() {
+	let from = 0, to = w
+	if (selmode == 2) {
+		from = Math.max(0, Math.floor(Math.min(selx, mk2.x) - mx))
+		to = Math.min(w, Math.ceil(Math.max(selx, mk2.x) - mx) + 1)
+	}
+	let series = []
+	data.forEach((d, i) => {
+		if (active === undefined || active === i) series.push(i)
+	})
+	return {from: from, to: to, series: series}
+}
+`
@@ -7,6 +7,7 @@ import (
 	"math"
 	"os"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/tomarus/chart/axis"
@@ -27,20 +28,38 @@ type Chart struct {
 	writer           io.Writer
 	axes             []*axis.Axis
 	sibase           int
+	legend           image.LegendOptions
+	sources          []source
+}
+
+// source is a data source which is queried when the chart is rendered.
+type source struct {
+	opt *data.Options
+	src data.DataSource
 }
 
 // Options defines a type used to initialize a Chart using NewChart()
 type Options struct {
 	Title         string      // guess what, leave empty to hide
 	Size          string      // big is 1440px, small is 720px, auto is size of dataset
-	Width, Height int         // overrides Size
+	Width, Height int         // overrides Size, images with a fixed size like term ignore both
 	Scheme        string      // palette colorscheme, default "white"
 	Theme         string      // if random scheme is used, set to "light" to use light colors, otherwise a dark theme is generated
 	Start, End    int64       // start + end epoch of data
 	Image         image.Image // the chart image type, chart.SVG{} or chart.PNG{}
 	W             io.Writer   // output writer to write image to
 	SIBase        int         // SI Base for auto axis calculation, default is 1000.
+	MarginX       int         // fixed left margin, by default it is calculated from the labels
+	MarginY       int         // fixed top and bottom margin, by default it is calculated from the labels
+	TitleFont     image.Font  // font of the title, zero fields use the image defaults
+	LabelFont     image.Font  // font of the axis labels and legend
+	Scale         float64     // device pixels per pixel for png images, e.g. 2 for HiDPI screens
 	Axes          []*axis.Axis
+	Legend        []string // legend statistics, e.g. "min", "max", "avg", "p95", "stddev", "sum", "last" or "count"
+
+	LegendPosition string                          // "bottom" (default), "top", "right" or "hidden"
+	LegendColumns  int                             // number of series columns in the legend, 0 wraps automatically
+	LegendFormat   map[string]func(float64) string // custom legend formatters by statistic name
 }
 
 // formats define the separator length, the time format and the
@@ -84,37 +103,155 @@ func (c *Chart) addAxes() {
 
 // Render renders the final image to the io.Writer.
 func (c *Chart) Render() error {
-	if len(c.data) == 0 {
-		return fmt.Errorf("no data available")
+	if err := c.query(); err != nil {
+		return err
 	}
-	if c.width < 100 {
-		return fmt.Errorf("image too small, set size or width or supply more datapoints")
+	mx, my, layout, err := c.setup()
+	if err != nil {
+		return err
 	}
-	c.data.Normalize(c.height)
-	sort.Sort(c.data)
+	if err := c.draw(mx, my, layout); err != nil {
+		return err
+	}
+	return c.image.End()
+}
 
+// setup adds the default axes, normalizes the data and returns the margins
+// and the layout of the image.
+func (c *Chart) setup() (mx, my int, l *image.Layout, err error) {
+	if len(c.data) == 0 {
+		return 0, 0, nil, fmt.Errorf("no data available")
+	}
 	if len(c.axes) == 0 {
 		c.addAxes()
 	}
+	if f, ok := c.image.(image.Fitter); ok {
+		c.fit(f)
+	}
+	if c.width < 100 {
+		return 0, 0, nil, fmt.Errorf("image too small, set size or width or supply more datapoints")
+	}
+	c.data.Normalize(c.height)
+	sort.Sort(c.data)
 
+	lo := c.legend
+	lo.Series = make([]func(float64) string, len(c.data))
 	for i := range c.data {
-		c.data[i].Scale = c.axes[1].Scales(c.height, 0, c.data[i].Max)
+		c.data[i].Scale = c.yaxis(i).Scales(c.height, 0, c.data[i].Max)
+		lo.Series[i] = c.yaxis(i).Format
 	}
 
-	c.image.Start(c.writer, c.width, c.height, c.marginx, c.marginy, c.start, c.end, c.palette, c.data)
+	mx, my, l = c.layout(&lo)
+	return mx, my, l, nil
+}
+
+// draw draws the chart on the image, without ending it.
+func (c *Chart) draw(mx, my int, layout *image.Layout) error {
+	c.image.Start(c.writer, c.width, c.height, mx, my, c.start, c.end, c.palette, c.data, layout)
 
 	err := c.image.Graph()
 	if err != nil {
 		return err
 	}
 
-	c.axes[0].Draw(c.image, c.width, c.height, c.marginx, c.marginy, float64(c.start), float64(c.end))
-	c.axes[1].Draw(c.image, c.width, c.height, c.marginx, c.marginy, 0, c.data[0].Max)
+	c.axes[0].Draw(c.image, c.width, c.height, mx, my, float64(c.start), float64(c.end))
+	c.yaxis(0).Draw(c.image, c.width, c.height, mx, my, 0, c.data[0].Max)
 
-	c.drawTitle(c.width+c.marginx, c.height)
-	c.image.Legend(float64(c.sibase))
-	c.image.Border(c.marginx-1, c.marginy-1, c.width+1, c.height+1)
-	return c.image.End()
+	c.drawPercentiles(mx, my)
+	c.drawTitle(c.width+mx, c.height)
+	c.image.Legend()
+	c.image.Border(mx-1, my-1, c.width+1, c.height+1)
+	return nil
+}
+
+// layout measures the axis labels, title and legend and returns the margins
+// of the chart area and the image layout. Margins set in the options are
+// used as is.
+func (c *Chart) layout(lo *image.LegendOptions) (mx, my int, l *image.Layout) {
+	mx, my = c.marginx, c.marginy
+	right := 4
+	if mx == 0 {
+		for i := range c.data {
+			if m := c.yaxis(i).Margin(c.image, c.height, 0, c.data[i].Max); m > mx {
+				mx = m
+			}
+		}
+		// the last time label may be centered on the right border
+		if tw, _ := c.image.MeasureText(image.GridRole, c.axes[0].Format(float64(c.end))); tw/2 > right {
+			right = tw / 2
+		}
+	}
+	if my == 0 {
+		my = c.axes[0].Margin(c.image, c.height, float64(c.start), float64(c.end))
+		if _, th := c.image.MeasureText(image.TitleRole, c.title); c.title != "" && th > my {
+			my = th
+		}
+		if my < 16 { // room for the svg buttons below the chart
+			my = 16
+		}
+		my += 4
+	}
+
+	legend := image.NewLegend(lo, c.image, c.data, c.width, c.height, mx, my)
+	top := my
+	l = &image.Layout{Width: mx + c.width + right, Height: my + c.height + my, Legend: legend,
+		XLabels: c.axes[0].Labels(), YLabels: c.axes[1].Labels()}
+	switch legend.Position {
+	case image.LegendTop:
+		top += legend.Height
+		l.Height += legend.Height
+	case image.LegendBottom:
+		l.Height += legend.Height
+	case image.LegendRight:
+		l.Width = legend.X + legend.Width + 8
+		if h := legend.Y + legend.Height + 4; h > l.Height {
+			l.Height = h
+		}
+	}
+	return mx, top, l
+}
+
+// fit sizes the chart area so the image fills the fixed size of f and
+// resamples the data to the new width.
+func (c *Chart) fit(f image.Fitter) {
+	w, h := f.Fit()
+	c.width, c.height = w, h
+	lo := c.legend
+	lo.Series = make([]func(float64) string, len(c.data))
+	for i := range c.data {
+		c.data[i].MinMaxAvg()
+		lo.Series[i] = c.yaxis(i).Format
+	}
+	// The legend may wrap differently when the width changes, so repeat
+	// until the layout fits.
+	for n := 0; n < 4; n++ {
+		_, _, l := c.layout(&lo)
+		if l.Width <= w && l.Height <= h {
+			break
+		}
+		if l.Width > w {
+			c.width -= l.Width - w
+		}
+		if l.Height > h {
+			c.height -= l.Height - h
+		}
+	}
+	// The data was resampled to the width of f when it was added, resample
+	// the source values again so the values are resampled only once.
+	for i := range c.data {
+		d := c.data[i].Window(0, len(c.data[i].Source()))
+		d.Resample(c.width)
+		c.data[i] = d
+	}
+}
+
+// yaxis returns a copy of the Y axis using the unit of dataset i, if any.
+func (c *Chart) yaxis(i int) *axis.Axis {
+	a := *c.axes[1]
+	if c.data[i].Unit != "" {
+		a.Unit(c.data[i].Unit)
+	}
+	return &a
 }
 
 // drawTitle sets the chart title.
@@ -125,11 +262,55 @@ func (c *Chart) drawTitle(width, height int) {
 	c.image.Text("title", "right", image.TitleRole, width-4, 12+2, c.title)
 }
 
+// drawPercentiles draws the percentile lines of all datasets which have one.
+func (c *Chart) drawPercentiles(mx, my int) {
+	max := c.data[0].Max
+	if max == 0 {
+		return
+	}
+	for i := range c.data {
+		p := c.data[i].PercentileLine()
+		if p == 0 {
+			continue
+		}
+		v := c.data[i].Percentile(p)
+		if math.IsNaN(v) {
+			continue
+		}
+		y := my + c.height - int(float64(c.height)*v/max)
+		col := c.palette.GetAxisColorName(i)
+		c.image.Line(col, mx, y, mx+c.width, y)
+		label := fmt.Sprintf("%s: %s", ordinal(p), c.yaxis(i).Format(v))
+		c.image.Text(col, "left", image.GridRole, mx+4, y-3, label)
+	}
+}
+
+// ordinal formats a percentile as an ordinal number, e.g. 95th.
+func ordinal(p float64) string {
+	s := strconv.FormatFloat(p, 'f', -1, 64)
+	n := int(p)
+	if float64(n) != p || (n%100 >= 11 && n%100 <= 13) {
+		return s + "th"
+	}
+	switch n % 10 {
+	case 1:
+		return s + "st"
+	case 2:
+		return s + "nd"
+	case 3:
+		return s + "rd"
+	}
+	return s + "th"
+}
+
 // AddData adds a single data set.
 func (c *Chart) AddData(opt *data.Options, d []float64) (err error) {
 	if opt.Type == "" {
 		opt.Type = "area"
 	}
+	if err := opt.Validate(); err != nil {
+		return err
+	}
 	newdata := data.NewData(opt, d)
 	if len(d) == 0 {
 		c.data = append(c.data, newdata)
@@ -151,17 +332,97 @@ func (c *Chart) AddData(opt *data.Options, d []float64) (err error) {
 	return err
 }
 
+// AddSource adds a data set which is queried from src when the chart is
+// rendered, at the resolution of the chart width between the start and end
+// of the chart.
+func (c *Chart) AddSource(opt *data.Options, src data.DataSource) error {
+	if src == nil {
+		return fmt.Errorf("no data source")
+	}
+	if err := opt.Validate(); err != nil {
+		return err
+	}
+	c.sources = append(c.sources, source{opt, src})
+	return nil
+}
+
+// query adds the data sets of all data sources.
+func (c *Chart) query() error {
+	if len(c.sources) == 0 {
+		return nil
+	}
+	if c.end <= c.start {
+		return fmt.Errorf("start and end are required to query data sources")
+	}
+	width := c.width
+	if f, ok := c.image.(image.Fitter); ok {
+		width, _ = f.Fit()
+	}
+	if width <= 0 {
+		width = 1440
+	}
+	start, end := time.Unix(c.start, 0), time.Unix(c.end, 0)
+	step := end.Sub(start) / time.Duration(width)
+	for _, s := range c.sources {
+		points, err := s.src.Query(start, end, step)
+		if err != nil {
+			return fmt.Errorf("query %q: %v", s.opt.Title, err)
+		}
+		// Fewer points are upsampled using the options of the data set.
+		n := width
+		if len(points) > 0 && len(points) < n {
+			n = len(points)
+		}
+		if err := c.AddData(s.opt, data.Points(points, start, end, n)); err != nil {
+			return err
+		}
+	}
+	c.sources = nil
+	return nil
+}
+
 // NewChart initializes a new svg chart.
 func NewChart(o *Options) (*Chart, error) {
 	w := o.W
 	if w == nil {
 		w = os.Stdout
 	}
-	c := &Chart{title: o.Title, marginx: 48, marginy: 20, image: o.Image, writer: w, data: data.Collection{}, axes: o.Axes, sibase: o.SIBase}
+	c := &Chart{title: o.Title, marginx: o.MarginX, marginy: o.MarginY, image: o.Image, writer: w, data: data.Collection{}, axes: o.Axes, sibase: o.SIBase}
 
 	if c.sibase == 0 {
 		c.sibase = 1000
 	}
+	if s, ok := c.image.(image.Scaler); ok && o.Scale > 0 {
+		s.SetScale(o.Scale)
+	}
+	if c.image != nil {
+		if err := c.image.Font(image.TitleRole, o.TitleFont); err != nil {
+			return nil, err
+		}
+		if err := c.image.Font(image.GridRole, o.LabelFont); err != nil {
+			return nil, err
+		}
+	}
+	c.legend = image.LegendOptions{
+		Position: o.LegendPosition,
+		Columns:  o.LegendColumns,
+		Stats:    image.DefaultLegend,
+		Format:   o.LegendFormat,
+		Base:     float64(c.sibase),
+	}
+	if len(o.Legend) > 0 {
+		for _, st := range o.Legend {
+			if !data.ValidStat(st) {
+				return nil, fmt.Errorf("unknown legend statistic %q", st)
+			}
+		}
+		c.legend.Stats = o.Legend
+	}
+	switch o.LegendPosition {
+	case "", image.LegendBottom, image.LegendTop, image.LegendRight, image.LegendHidden:
+	default:
+		return nil, fmt.Errorf("unknown legend position %q", o.LegendPosition)
+	}
 	if o.Size == "big" {
 		c.width = 1440
 		c.height = 360
@@ -178,6 +439,9 @@ func NewChart(o *Options) (*Chart, error) {
 	if o.Height > 0 {
 		c.height = o.Height
 	}
+	if f, ok := c.image.(image.Fitter); ok {
+		c.width, c.height = f.Fit()
+	}
 
 	c.start = o.Start
 	c.end = o.End
@@ -3,16 +3,28 @@ package chart
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"image/gif"
+	stdpng "image/png"
+	"math"
 	"os"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/tomarus/chart/axis"
+	"github.com/tomarus/chart/canvas"
 	"github.com/tomarus/chart/data"
 	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/pdf"
 	"github.com/tomarus/chart/png"
+	"github.com/tomarus/chart/spec"
 	"github.com/tomarus/chart/svg"
+	"github.com/tomarus/chart/term"
 )
 
 func TestChart(t *testing.T) {
@@ -99,6 +111,10 @@ func TestChart(t *testing.T) {
 	}
 	// TODO: actually test svg output somehow
 
+	if err := c.AddData(&data.Options{Downsample: "median"}, []float64{1, 2}); err == nil || len(c.data) != 1 {
+		t.Error("expected error for unknown downsample method")
+	}
+
 	opts.Image = png.New()
 	c, _ = NewChart(opts)
 	c.AddData(&data.Options{}, []float64{1, 2, 3, 4, 5, 6})
@@ -109,6 +125,349 @@ func TestChart(t *testing.T) {
 	// TODO: actually test png output somehow
 }
 
+func TestChartNaN(t *testing.T) {
+	for _, img := range []image.Image{svg.New(), png.New()} {
+		var out bytes.Buffer
+		c, _ := NewChart(&Options{Image: img, Width: 100, Height: 50, W: &out})
+		c.AddData(&data.Options{Title: "gaps"}, []float64{1, math.NaN(), 3, math.NaN(), 5})
+		if err := c.Render(); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
+
+func TestLegend(t *testing.T) {
+	for _, pos := range []string{"bottom", "top", "right", "hidden"} {
+		for _, img := range []image.Image{svg.New(), png.New(), pdf.New(), canvas.New()} {
+			var out bytes.Buffer
+			c, err := NewChart(&Options{
+				Image:          img,
+				Width:          400,
+				Height:         100,
+				W:              &out,
+				Legend:         []string{"avg", "last"},
+				LegendPosition: pos,
+				LegendFormat:   map[string]func(float64) string{"last": func(v float64) string { return fmt.Sprintf("%.3f", v) }},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3; i++ {
+				c.AddData(&data.Options{Title: fmt.Sprintf("series %d", i)}, []float64{1, 2, 3, 4, 5})
+			}
+			if err := c.Render(); err != nil {
+				t.Errorf("%s: unexpected error %v", pos, err)
+			}
+		}
+	}
+
+	if _, err := NewChart(&Options{LegendPosition: "left"}); err == nil {
+		t.Error("expected error for unknown legend position")
+	}
+
+	d := make(data.Collection, 35)
+	for i := range d {
+		d[i] = data.NewData(&data.Options{Title: "s"}, []float64{1, 2})
+	}
+	o := &image.LegendOptions{Stats: image.DefaultLegend, Base: 1000}
+	l := image.NewLegend(o, svg.New(), d, 1440, 300, 48, 20)
+	if l.Columns != 4 || l.Rows != 9 {
+		t.Errorf("Expected 35 series to wrap in 4 columns of 9 rows, got %d x %d", l.Columns, l.Rows)
+	}
+	if x, y := l.Entry(9); x != l.Column(1) || y != l.Y+16 {
+		t.Errorf("Expected entry 9 at the top of the second column, got %d,%d", x, y)
+	}
+	if l.Y != 300+40 || l.Height != 10*16 {
+		t.Errorf("Expected legend below the chart, got %d height %d", l.Y, l.Height)
+	}
+
+	// columns are aligned by runes, not bytes
+	o.Format = map[string]func(float64) string{"max": func(v float64) string { return fmt.Sprintf("%.0fµs", v) }}
+	l = image.NewLegend(o, svg.New(), d, 1440, 300, 48, 20)
+	if h, s := l.Header(), l.Stats(0); h != " Min  Max   Avg" || s != "1.00  2µs  1.50" {
+		t.Errorf("Expected aligned columns, got %q and %q", h, s)
+	}
+	o.Format = nil
+
+	o.Position = "right"
+	l = image.NewLegend(o, svg.New(), d, 1440, 300, 48, 20)
+	if l.Columns != 2 || l.X != 48+1440+16 {
+		t.Errorf("Expected 2 columns right of the chart, got %d at %d", l.Columns, l.X)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	for _, pos := range []string{"bottom", "top", "right"} {
+		c, _ := NewChart(&Options{Image: svg.New(), Width: 200, Height: 100, MarginX: 48, MarginY: 20, LegendPosition: pos})
+		c.AddData(&data.Options{Title: "a"}, []float64{1, 2})
+		c.AddData(&data.Options{Title: "b"}, []float64{1, 2})
+		c.addAxes()
+		mx, my, l := c.layout(&c.legend)
+		lh := l.Legend.Height
+		switch {
+		case mx != 48:
+			t.Errorf("%s: Expected fixed margin 48, got %d", pos, mx)
+		case pos == "bottom" && (my != 20 || l.Height != 20+100+20+lh || l.Width != 48+200+4):
+			t.Errorf("%s: Unexpected layout %d %+v", pos, my, l)
+		case pos == "top" && (my != 20+lh || l.Height != 20+lh+100+20):
+			t.Errorf("%s: Unexpected layout %d %+v", pos, my, l)
+		case pos == "right" && (my != 20 || l.Width != l.Legend.X+l.Legend.Width+8):
+			t.Errorf("%s: Unexpected layout %d %+v", pos, my, l)
+		}
+	}
+
+	// automatic margins fit the widest label
+	c, _ := NewChart(&Options{Image: svg.New(), Width: 200, Height: 100, LegendPosition: "hidden"})
+	c.AddData(&data.Options{}, []float64{1, 1023.9e6})
+	c.data.Normalize(c.height)
+	c.addAxes()
+	mx, _, _ := c.layout(&c.legend)
+	for _, label := range c.axes[1].Scales(c.height, 0, c.data[0].Max) {
+		if w, _ := c.image.MeasureText(image.GridRole, label); mx < w+8 {
+			t.Errorf("Expected margin to fit label %s of %dpx, got %d", label, w, mx)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	var out bytes.Buffer
+	c, _ := NewChart(&Options{
+		Image:  svg.New(),
+		Width:  100,
+		Height: 50,
+		W:      &out,
+		Axes: []*axis.Axis{
+			axis.NewTime(axis.Bottom, "15:04").Ticks(2),
+			axis.New(axis.Left, func(v float64) string { return fmt.Sprintf("%.1f", v) }).Ticks(2),
+		},
+	})
+	c.AddData(&data.Options{Title: "load", Unit: "%", PercentileLine: 50}, []float64{10, 20, 30, 40, 50})
+	if err := c.Render(); err != nil {
+		t.Fatal(err)
+	}
+	res := out.String()
+	for _, s := range []string{
+		`"unit":"%"`,
+		`"scale":["50.0%","25.0%","0.0%"]`, // scales used by the svg readout
+		`>50th: 30.0%<`,                    // percentile line
+		`>10.0%  50.0%  30.0%<`,            // legend using the axis formatter
+		`const [xfmt,yfmt]=[{"format":"time","layout":"15:04"},{"format":""}]`, // custom formatters are not known by live charts
+	} {
+		if !strings.Contains(res, s) {
+			t.Errorf("Expected %s in svg output", s)
+		}
+	}
+
+	out.Reset()
+	c, _ = NewChart(&Options{
+		Image:  svg.New(),
+		Width:  100,
+		Height: 50,
+		W:      &out,
+		Axes:   []*axis.Axis{axis.NewTime(axis.Bottom, "02 Jan").Ticks(2), axis.NewSI(axis.Left, 1024).Unit("B").Ticks(2)},
+	})
+	c.AddData(&data.Options{Title: "disk"}, []float64{10, 20, 30, 40, 50})
+	if err := c.Render(); err != nil {
+		t.Fatal(err)
+	}
+	if s := `const [xfmt,yfmt]=[{"format":"time","layout":"02 Jan"},{"format":"si","base":1024,"unit":"B"}]`; !strings.Contains(out.String(), s) {
+		t.Errorf("Expected %s in svg output", s)
+	}
+}
+
+func TestFonts(t *testing.T) {
+	font := image.Font{Family: "sans", Size: 20, Weight: "bold"}
+	for _, img := range []image.Image{svg.New(), png.New(), pdf.New(), canvas.New()} {
+		w1, h1 := img.MeasureText(image.GridRole, "1023.9M")
+		var out bytes.Buffer
+		c, err := NewChart(&Options{Image: img, Width: 100, Height: 50, W: &out, LabelFont: font})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w2, h2 := img.MeasureText(image.GridRole, "1023.9M"); w2 <= w1 || h2 <= h1 {
+			t.Errorf("Expected a larger font, got %dx%d and %dx%d", w1, h1, w2, h2)
+		}
+		c.AddData(&data.Options{}, []float64{1, 2, 3})
+		if err := c.Render(); err != nil {
+			t.Error(err)
+		}
+		if _, ok := img.(*svg.SVG); ok && !strings.Contains(out.String(), "font-size: 20px; font-family: sans; font-weight: bold;") {
+			t.Error("Expected font in svg css")
+		}
+		if _, ok := img.(*canvas.Canvas); ok && !strings.Contains(out.String(), `"bold 20px sans"`) {
+			t.Error("Expected font in canvas script")
+		}
+	}
+
+	for _, img := range []image.Image{png.New(), pdf.New()} {
+		if _, err := NewChart(&Options{Image: img, LabelFont: image.Font{File: "testdata/missing.ttf"}}); err == nil {
+			t.Error("Expected error for missing font file")
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	size := func(scale float64) (int, int) {
+		var out bytes.Buffer
+		c, _ := NewChart(&Options{Image: png.New(), Width: 100, Height: 50, W: &out, Scale: scale, Title: "scaled"})
+		c.AddData(&data.Options{Title: "a"}, []float64{1, 2, 3})
+		if err := c.Render(); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := stdpng.DecodeConfig(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg.Width, cfg.Height
+	}
+	w1, h1 := size(0)
+	w2, h2 := size(2)
+	if w2 != 2*w1 || h2 != 2*h1 {
+		t.Errorf("Expected %dx%d image, got %dx%d", 2*w1, 2*h1, w2, h2)
+	}
+}
+
+func TestTimelapse(t *testing.T) {
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = float64(i * i)
+	}
+	render := func(img *png.PNG) []byte {
+		var out bytes.Buffer
+		c, _ := NewChart(&Options{Image: img, Width: 200, Height: 50, W: &out, Start: 0, End: 100 * 60})
+		c.AddData(&data.Options{Title: "a"}, values)
+		c.AddData(&data.Options{Title: "b"}, values[:50])
+		if err := c.Timelapse(&TimelapseOptions{Window: 40, Step: 25, FPS: 4}); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	// windows start at 0, 25, 50 and the last 60
+	anim, err := gif.DecodeAll(bytes.NewReader(render(png.New().Encoding(png.GIF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 4 {
+		t.Fatalf("Expected 4 frames, got %d", len(anim.Image))
+	}
+	for i, m := range anim.Image {
+		if m.Bounds() != anim.Image[0].Bounds() || anim.Delay[i] != 25 {
+			t.Errorf("Frame %d: got bounds %v and delay %d", i, m.Bounds(), anim.Delay[i])
+		}
+	}
+
+	b := render(png.New())
+	if _, err := stdpng.Decode(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b, []byte("fcTL")); n != 4 {
+		t.Errorf("Expected 4 animated png frames, got %d", n)
+	}
+	if i := bytes.Index(b, []byte("acTL")); i < 0 || b[i+7] != 4 {
+		t.Error("Expected animation control chunk with 4 frames")
+	}
+
+	c, _ := NewChart(&Options{Image: svg.New(), Width: 200})
+	c.AddData(&data.Options{}, values)
+	if err := c.Timelapse(&TimelapseOptions{Window: 10}); err == nil {
+		t.Error("Expected error for images which don't support animations")
+	}
+}
+
+func TestSpec(t *testing.T) {
+	var out bytes.Buffer
+	c, _ := NewChart(&Options{Image: spec.New(), Title: "spec", Width: 200, Height: 100, W: &out, Start: 0, End: 86400, Legend: []string{"max", "p95"}})
+	c.AddData(&data.Options{Title: "a", Unit: "B", PercentileLine: 95}, []float64{1, 2, math.NaN(), 4})
+	c.AddData(&data.Options{Title: "b"}, []float64{1, 1, 1, 1})
+	if err := c.Render(); err != nil {
+		t.Fatal(err)
+	}
+	var doc spec.Chart
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != spec.Version || doc.Title == nil || doc.Title.Text != "spec" {
+		t.Errorf("Unexpected document %+v", doc)
+	}
+	if doc.Area.Width != 200 || doc.Area.Height != 100 || len(doc.Series) != 2 || len(doc.Series[0].Values) != 200 {
+		t.Errorf("Unexpected chart area %+v", doc.Area)
+	}
+	y := doc.YAxis.Ticks
+	if len(y) != len(doc.Series[0].Scale) || y[0].Pos != 100 || y[0].Label != doc.Series[0].Scale[0] || y[0].Value != 4 || y[len(y)-1].Pos != 0 {
+		t.Errorf("Unexpected Y axis ticks %+v", y)
+	}
+	if x := doc.XAxis.Ticks; len(x) == 0 || x[0].Value != float64(x[0].Pos)*86400/200 {
+		t.Errorf("Unexpected X axis ticks %+v", x)
+	}
+	if len(doc.XAxis.Grid) == 0 || len(doc.YAxis.Grid) == 0 {
+		t.Error("Expected grid lines")
+	}
+	a := doc.Series[0]
+	if a.Values[100] != data.Missing || !math.IsNaN(a.Raw[100]) {
+		t.Errorf("Expected missing values, got %d %f", a.Values[100], a.Raw[100])
+	}
+	if a.Percentile == nil || a.Percentile.Percentile != 95 || len(doc.Annotations) != 2 {
+		t.Errorf("Expected percentile line and label, got %+v %+v", a.Percentile, doc.Annotations)
+	}
+	if a.Legend == nil || len(a.Legend.Stats) != 2 || a.Legend.Stats[0].Name != "max" || a.Legend.Stats[0].Value != 4 || a.Legend.Stats[0].Label != "4.00B" {
+		t.Errorf("Unexpected legend %+v", a.Legend)
+	}
+	if _, ok := doc.Colors[a.Color]; !ok {
+		t.Errorf("Expected series color %s in colors", a.Color)
+	}
+}
+
+func TestTerm(t *testing.T) {
+	ansi := regexp.MustCompile("\x1b\\[[0-9;]*m")
+	for _, pos := range []string{"bottom", "top", "right"} {
+		for _, img := range []*term.Term{
+			term.New(80, 24),
+			term.New(100, 30).Charset(term.Blocks).Colors(term.TrueColor),
+			term.New(60, 20).Charset(term.ASCII).Colors(term.NoColor),
+		} {
+			var out bytes.Buffer
+			c, _ := NewChart(&Options{Title: "Terminal", Image: img, W: &out, LegendPosition: pos})
+			c.AddData(&data.Options{Title: "a"}, []float64{1, 2, 3, 4, 5, 6, 7, 8})
+			c.AddData(&data.Options{Title: "b", Smooth: data.Smoothing{Method: data.SMA, Window: 3}}, []float64{3, 2, 1, 2, 3, 2, 1, 2})
+			if err := c.Render(); err != nil {
+				t.Fatal(err)
+			}
+			cols, rows := img.Fit()
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(lines) != rows/16 {
+				t.Errorf("%s: Expected %d rows, got %d", pos, rows/16, len(lines))
+			}
+			for _, l := range lines {
+				if n := utf8.RuneCountInString(ansi.ReplaceAllString(l, "")); n > cols/8 {
+					t.Errorf("%s: Expected at most %d columns, got %d: %q", pos, cols/8, n, l)
+				}
+			}
+			if !strings.Contains(out.String(), "Terminal") {
+				t.Errorf("%s: Expected title in output", pos)
+			}
+		}
+	}
+
+	// the source values are resampled once, to the width left by the legend
+	src := make([]float64, 2000)
+	for i := range src {
+		src[i] = float64(i % 10)
+	}
+	var out bytes.Buffer
+	c, _ := NewChart(&Options{Image: term.New(80, 24), W: &out})
+	c.AddData(&data.Options{Title: "envelope", Envelope: true}, src)
+	if err := c.Render(); err != nil {
+		t.Fatal(err)
+	}
+	expect := data.Collection{data.NewData(&data.Options{Envelope: true}, src)}
+	expect[0].Resample(c.width)
+	expect.Normalize(c.height)
+	if d := c.data[0]; !reflect.DeepEqual(d.Raw(), expect[0].Raw()) || !reflect.DeepEqual(d.High, expect[0].High) {
+		t.Errorf("Expected the source values resampled to %d, got %v %v", c.width, d.Raw(), d.High)
+	}
+}
+
 func testimg(img image.Image) {
 	var out bytes.Buffer
 	w := bufio.NewWriter(&out)
@@ -189,3 +548,56 @@ func ExampleChart() {
 	}
 	c.Render()
 }
+
+func TestSource(t *testing.T) {
+	var steps []time.Duration
+	src := data.SourceFunc(func(start, end time.Time, step time.Duration) ([]data.Point, error) {
+		steps = append(steps, step)
+		var p []data.Point
+		for tm := start; tm.Before(end); tm = tm.Add(time.Minute) {
+			p = append(p, data.Point{Time: tm, Value: float64(tm.Unix() % 3600)})
+		}
+		return p, nil
+	})
+	for _, x := range []struct {
+		img   image.Image
+		width int
+	}{
+		{spec.New(), 200},
+		{term.New(80, 24), 0},
+	} {
+		steps = nil
+		var out bytes.Buffer
+		c, _ := NewChart(&Options{Image: x.img, Width: x.width, Height: 100, W: &out, Start: 0, End: 86400})
+		if err := c.AddSource(&data.Options{Title: "src", Upsample: "linear"}, src); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Render(); err != nil {
+			t.Fatal(err)
+		}
+		w := x.width
+		if f, ok := x.img.(image.Fitter); ok {
+			w, _ = f.Fit()
+		}
+		if len(c.data) != 1 || c.data[0].Len() != c.width || steps[0] != 86400*time.Second/time.Duration(w) {
+			t.Errorf("Expected %d values at a step of %v, got %d at %v", c.width, 86400*time.Second/time.Duration(w), c.data[0].Len(), steps)
+		}
+		if err := c.Render(); err != nil || len(steps) != 1 || len(c.data) != 1 {
+			t.Errorf("Expected a single query, got %d %v", len(steps), err)
+		}
+	}
+
+	fail := data.SourceFunc(func(start, end time.Time, step time.Duration) ([]data.Point, error) {
+		return nil, fmt.Errorf("unavailable")
+	})
+	c, _ := NewChart(&Options{Image: svg.New(), Width: 200, Height: 100, Start: 0, End: 60})
+	c.AddSource(&data.Options{Title: "down"}, fail)
+	if err := c.Render(); err == nil || err.Error() != `query "down": unavailable` {
+		t.Errorf("Expected query error, got %v", err)
+	}
+	c, _ = NewChart(&Options{Image: svg.New(), Width: 200, Height: 100})
+	c.AddSource(&data.Options{}, src)
+	if err := c.Render(); err == nil {
+		t.Error("Expected error without start and end")
+	}
+}
@@ -0,0 +1,315 @@
+// Command chart renders charts of csv or tsv data read from stdin or files.
+//
+// Each row is a timestamp followed by one or more values, each value column
+// is a series. Timestamps are epoch seconds, epoch milliseconds or RFC3339,
+// detected automatically. An optional header row contains the series titles.
+//
+//	curl -s $QUERY | chart -title Requests -legend max,avg,p95 -o requests.png
+//	chart -x-layout "02 Jan" -y-format si -y-base 1024 -y-unit B disk.tsv
+//
+// The chart is written to stdout, in the terminal when stdout is a terminal,
+// or to the -o file in the image format of its extension.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tomarus/chart/config"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/png"
+	"github.com/tomarus/chart/term"
+)
+
+// maxAutoWidth limits the width of auto sized charts, which have one pixel
+// per value.
+const maxAutoWidth = 4096
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, isTerminal(os.Stdout)); err != nil {
+		fmt.Fprintln(os.Stderr, "chart:", err)
+		os.Exit(1)
+	}
+}
+
+// isTerminal returns true if f is a character device.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// extensions are the images by the extension of the output file.
+var extensions = map[string]string{
+	".svg":  "svg",
+	".png":  "png",
+	".gif":  "gif",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".html": "canvas",
+	".pdf":  "pdf",
+	".json": "spec",
+	".txt":  "term",
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, tty bool) error {
+	fs := flag.NewFlagSet("chart", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chart [flags] [file ...]")
+		fs.PrintDefaults()
+	}
+	d := &config.Definition{Axes: &config.Axes{X: &config.Axis{}, Y: &config.Axis{}}, Legend: &config.Legend{}}
+
+	out := fs.String("o", "", "output `file`, stdout by default")
+	fs.StringVar(&d.Image, "image", "", "svg, png, gif, jpeg, canvas, pdf, spec, term, sixel or kitty, by default from the -o extension or term on a terminal")
+	delim := fs.String("d", "", "field delimiter, detected from the first line by default, use \\t for tabs")
+	timefmt := fs.String("time", Auto, "timestamp format, auto, s, ms or rfc3339")
+
+	fs.StringVar(&d.Title, "title", "", "chart title")
+	fs.StringVar(&d.Size, "size", "big", "big (1440px), small (720px) or auto (one pixel per value, at most 4096px), terminals use their own size")
+	fs.IntVar(&d.Width, "width", 0, "image width in pixels, overrides -size")
+	fs.IntVar(&d.Height, "height", 0, "image height in pixels")
+	fs.Float64Var(&d.Scale, "scale", 0, "device pixels per pixel of png images, e.g. 2 for HiDPI screens")
+	fs.StringVar(&d.Scheme, "scheme", "", "palette color scheme, e.g. white, black or random")
+	fs.StringVar(&d.Theme, "theme", "", "light or dark theme of random schemes")
+	start := fs.String("start", "", "time of the first value, from the data by default")
+	end := fs.String("end", "", "time after the last value, from the data by default")
+	fs.IntVar(&d.SIBase, "sibase", 0, "SI base of the default y axis, 1000 or 1024")
+	fs.IntVar(&d.MarginX, "marginx", 0, "fixed left margin in pixels")
+	fs.IntVar(&d.MarginY, "marginy", 0, "fixed top and bottom margin in pixels")
+	titleFont := fs.String("title-font", "", "title font as family,size,weight or a font file")
+	labelFont := fs.String("label-font", "", "label font as family,size,weight or a font file")
+
+	for _, a := range []struct {
+		name string
+		ax   *config.Axis
+	}{{"x", d.Axes.X}, {"y", d.Axes.Y}} {
+		fs.StringVar(&a.ax.Format, a.name+"-format", "", a.name+" axis label format, time, si or float")
+		fs.StringVar(&a.ax.Layout, a.name+"-layout", "", a.name+" axis Go time layout of the time format, e.g. 15:04")
+		fs.IntVar(&a.ax.Base, a.name+"-base", 0, a.name+" axis SI base of the si format")
+		fs.IntVar(&a.ax.Decimals, a.name+"-decimals", 0, a.name+" axis decimals of the float format")
+		fs.StringVar(&a.ax.Unit, a.name+"-unit", "", a.name+" axis unit")
+		fs.IntVar(&a.ax.Ticks, a.name+"-ticks", 0, a.name+" axis number of labels")
+		fs.StringVar(&a.ax.Duration, a.name+"-duration", "", a.name+" axis time between labels, e.g. 4h, instead of ticks")
+		fs.IntVar(&a.ax.Grid, a.name+"-grid", 0, a.name+" axis number of grid lines between labels")
+		fs.BoolVar(&a.ax.Center, a.name+"-center", false, a.name+" axis centers the labels between ticks")
+	}
+
+	legend := fs.String("legend", "", "legend statistics, e.g. min,max,avg,p95,stddev,sum,last,count")
+	fs.StringVar(&d.Legend.Position, "legend-position", "", "bottom, top, right or hidden")
+	fs.IntVar(&d.Legend.Columns, "legend-columns", 0, "number of legend columns, wraps automatically by default")
+
+	typ := fs.String("type", "", "series type, area or line")
+	unit := fs.String("unit", "", "series unit shown in the legend")
+
+	cols := fs.Int("cols", envInt("COLUMNS", 80), "terminal width in characters")
+	rows := fs.Int("rows", envInt("LINES", 24)-1, "terminal height in characters")
+	charset := fs.String("charset", term.Braille, "terminal characters, braille, blocks or ascii")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config.Images["term"] = func() image.Image { return term.New(*cols, *rows).Charset(*charset) }
+	config.Images["sixel"] = func() image.Image { return term.NewSixel() }
+	config.Images["kitty"] = func() image.Image { return term.NewKitty() }
+	config.Images["gif"] = func() image.Image { return png.New().Encoding(png.GIF) }
+	config.Images["jpeg"] = func() image.Image { return png.New().Encoding(png.JPEG) }
+	if d.Image == "" {
+		switch {
+		case *out != "":
+			d.Image = extensions[strings.ToLower(filepath.Ext(*out))]
+		case tty:
+			d.Image = "term"
+		}
+	}
+
+	comma, err := delimiter(*delim)
+	if err != nil {
+		return err
+	}
+	var tables []*table
+	if fs.NArg() == 0 {
+		t, err := readTable(stdin, comma, *timefmt)
+		if err != nil {
+			return fmt.Errorf("stdin: %v", err)
+		}
+		tables = append(tables, t)
+	}
+	for _, name := range fs.Args() {
+		t, err := readFile(name, comma, *timefmt)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, t)
+	}
+	s := resample(tables)
+	if len(s.values) == 0 {
+		return fmt.Errorf("no value columns")
+	}
+	if (d.Size == "auto" || d.Size == "") && d.Width == 0 && len(s.values[0]) > maxAutoWidth {
+		d.Size, d.Width = "", maxAutoWidth
+		if d.Height == 0 {
+			d.Height = maxAutoWidth / 4
+		}
+	}
+
+	d.Start, d.End = config.Time(strconv.FormatInt(s.start, 10)), config.Time(strconv.FormatInt(s.end, 10))
+	if *start != "" {
+		d.Start = config.Time(*start)
+	}
+	if *end != "" {
+		d.End = config.Time(*end)
+	}
+	if d.TitleFont, err = font(*titleFont); err != nil {
+		return fmt.Errorf("-title-font: %v", err)
+	}
+	if d.LabelFont, err = font(*labelFont); err != nil {
+		return fmt.Errorf("-label-font: %v", err)
+	}
+	if !axisFlags(fs) {
+		d.Axes = nil
+	}
+	if *legend != "" {
+		d.Legend.Stats = strings.Split(*legend, ",")
+	}
+	src := config.Values{}
+	for i, v := range s.values {
+		ref := strconv.Itoa(i)
+		title := s.titles[i]
+		if title == "" {
+			title = "column " + strconv.Itoa(i+2)
+		}
+		d.Series = append(d.Series, &config.Series{Title: title, Source: ref, Type: *typ, Unit: *unit})
+		src[ref] = v
+	}
+
+	if err := d.Validate(); err != nil {
+		return flagError(err)
+	}
+	w := stdout
+	var f *os.File
+	if *out != "" {
+		if f, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	c, err := d.Chart(w, src)
+	if err != nil {
+		return err
+	}
+	if err := c.Render(); err != nil {
+		return err
+	}
+	if f != nil {
+		return f.Close()
+	}
+	return nil
+}
+
+// flagError returns a definition error as an error of the flag which sets
+// the field, e.g. axes.y.format is -y-format.
+func flagError(err error) error {
+	e, ok := err.(*config.Error)
+	if !ok {
+		return err
+	}
+	p := e.Path
+	if i := strings.IndexByte(p, '['); i >= 0 {
+		p = p[:i] + p[strings.IndexByte(p, ']')+1:]
+	}
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "axes."), "series.")
+	switch p = strings.Replace(p, ".", "-", -1); p {
+	case "legend-stats":
+		p = "legend"
+	case "axes":
+		return e
+	}
+	return fmt.Errorf("-%s: %v", p, e.Err)
+}
+
+// readFile reads the table of the file name.
+func readFile(name string, comma rune, timefmt string) (*table, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if comma == 0 && strings.EqualFold(filepath.Ext(name), ".tsv") {
+		comma = '\t'
+	}
+	t, err := readTable(f, comma, timefmt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return t, nil
+}
+
+// delimiter returns the delimiter of the -d flag.
+func delimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	if r := []rune(s); len(r) == 1 {
+		return r[0], nil
+	}
+	return 0, fmt.Errorf("-d: delimiter must be a single character, got %q", s)
+}
+
+// font parses a font flag, family,size,weight or the path of a font file.
+func font(s string) (*config.Font, error) {
+	if s == "" {
+		return nil, nil
+	}
+	switch strings.ToLower(filepath.Ext(s)) {
+	case ".ttf", ".otf":
+		return &config.Font{File: s}, nil
+	}
+	f := &config.Font{}
+	parts := strings.Split(s, ",")
+	f.Family = parts[0]
+	if len(parts) > 1 {
+		size, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q", parts[1])
+		}
+		f.Size = size
+	}
+	if len(parts) > 2 {
+		f.Weight = parts[2]
+	}
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("expected family,size,weight, got %q", s)
+	}
+	return f, nil
+}
+
+// axisFlags returns true if any axis flag is set, which replaces the
+// default axes.
+func axisFlags(fs *flag.FlagSet) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "x-") || strings.HasPrefix(f.Name, "y-") {
+			set = true
+		}
+	})
+	return set
+}
+
+// envInt returns the integer environment variable name or def.
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tomarus/chart/spec"
+)
+
+func TestReadTable(t *testing.T) {
+	for _, x := range []struct {
+		in     string
+		titles []string
+		times  []int64
+	}{
+		{"1700000000,1\n1700000060,2\n", nil, []int64{1700000000000, 1700000060000}},
+		{"1700000000.5,1\n", nil, []int64{1700000000500}},
+		{"time,in,out\n1700000000000,1,2\n1700000060000,3,4\n", []string{"in", "out"}, []int64{1700000000000, 1700000060000}},
+		{"time\tin\n2023-11-14T22:13:20Z\t1\n2023-11-14T23:13:20+01:00\t2\n", []string{"in"}, []int64{1700000000000, 1700000000000}},
+		{"# comment\n60,1\n0,2\n", nil, []int64{60000, 0}},
+	} {
+		tab, err := readTable(strings.NewReader(x.in), 0, Auto)
+		if err != nil {
+			t.Fatalf("%q: %v", x.in, err)
+		}
+		if strings.Join(tab.titles, ",") != strings.Join(x.titles, ",") {
+			t.Errorf("%q: Expected titles %v, got %v", x.in, x.titles, tab.titles)
+		}
+		for i := range x.times {
+			if tab.times[i] != x.times[i] {
+				t.Errorf("%q: Expected times %v, got %v", x.in, x.times, tab.times)
+				break
+			}
+		}
+	}
+
+	for in, msg := range map[string]string{
+		"":                   "no rows",
+		"time,a\n":           "no rows",
+		"0,1\nyesterday,2\n": `line 2: invalid epoch "yesterday"`,
+		"t,a\n0,1\n60,x\n":   `line 3, column 2: invalid value "x"`,
+	} {
+		if _, err := readTable(strings.NewReader(in), 0, Auto); err == nil || err.Error() != msg {
+			t.Errorf("%q: Expected %q, got %v", in, msg, err)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	a := &table{times: []int64{120000, 0, 60000, 60000, 240000}, rows: [][]float64{{3}, {1}, {2}, {4}, {5}}}
+	b := &table{titles: []string{"b"}, times: []int64{30000, 90000}, rows: [][]float64{{1, math.NaN()}, {2}}}
+	s := resample([]*table{a, b})
+	if s.start != 0 || s.end != 300 {
+		t.Errorf("Expected 0-300, got %d-%d", s.start, s.end)
+	}
+	if len(s.values) != 3 || s.titles[1] != "b" {
+		t.Fatalf("Expected 3 series, got %v", s.titles)
+	}
+	expect := []float64{1, 3, 3, math.NaN(), 5}
+	for i, v := range s.values[0] {
+		if v != expect[i] && !(math.IsNaN(v) && math.IsNaN(expect[i])) {
+			t.Errorf("Expected %v, got %v", expect, s.values[0])
+			break
+		}
+	}
+	if s.values[1][0] != 1 || s.values[1][1] != 2 || !math.IsNaN(s.values[2][0]) {
+		t.Errorf("Unexpected merged series %v", s.values)
+	}
+}
+
+func TestRun(t *testing.T) {
+	in := "time,rx,tx\n1700000000,1,4\n1700000060,2,3\n1700000120,3,2\n1700000180,4,1\n"
+	var out bytes.Buffer
+	err := run([]string{"-image", "spec", "-width", "300", "-height", "100", "-y-format", "float", "-y-unit", " B", "-legend", "max"}, strings.NewReader(in), &out, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc spec.Chart
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Start != 1700000000 || doc.End != 1700000240 || len(doc.Series) != 2 || doc.Series[1].Title != "tx" {
+		t.Errorf("Unexpected chart %d-%d %+v", doc.Start, doc.End, doc.Series)
+	}
+	if y := doc.YAxis.Ticks; len(y) == 0 || !strings.HasSuffix(y[0].Label, " B") {
+		t.Errorf("Expected float labels, got %+v", y)
+	}
+
+	var rows strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&rows, "%d,%d\n", 1700000000+i*60, i)
+	}
+	for _, x := range []struct {
+		args  []string
+		width int
+	}{
+		{[]string{"-image", "spec"}, 1440},
+		{[]string{"-image", "spec", "-size", "auto"}, maxAutoWidth},
+	} {
+		out.Reset()
+		if err := run(x.args, strings.NewReader(rows.String()), &out, false); err != nil {
+			t.Fatal(err)
+		}
+		doc = spec.Chart{}
+		if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Area.Width != x.width {
+			t.Errorf("%v: Expected width %d, got %d", x.args, x.width, doc.Area.Width)
+		}
+	}
+
+	out.Reset()
+	if err := run([]string{"-charset", "ascii"}, strings.NewReader(in), &out, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "rx") || strings.Contains(out.String(), "<svg") {
+		t.Errorf("Expected a terminal chart, got %q", out.String())
+	}
+
+	for args, msg := range map[string]string{
+		"-y-format hex":          `-y-format: unknown formatter "hex"`,
+		"-legend max,mode":       `-legend: unknown legend statistic "mode"`,
+		"-type bars":             `-type: unknown type "bars"`,
+		"-image bmp":             `-image: unknown image "bmp"`,
+		"-d ab":                  `-d: delimiter must be a single character, got "ab"`,
+		"-title-font sans,large": `-title-font: invalid size "large"`,
+	} {
+		err := run(strings.Fields(args), strings.NewReader(in), &out, false)
+		if err == nil || err.Error() != msg {
+			t.Errorf("%s: Expected %q, got %v", args, msg, err)
+		}
+	}
+}
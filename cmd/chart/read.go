@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp formats of the first column.
+const (
+	Auto    = "auto"    // detected from the values
+	Seconds = "s"       // epoch seconds, may be fractional
+	Millis  = "ms"      // epoch milliseconds
+	RFC3339 = "rfc3339" // e.g. 2006-01-02T15:04:05Z
+)
+
+// maxPoints limits the number of values of each series after resampling.
+const maxPoints = 1000000
+
+// table is a parsed csv or tsv file.
+type table struct {
+	titles []string    // titles of the value columns
+	times  []int64     // epoch milliseconds of each row
+	rows   [][]float64 // values of each row
+}
+
+// readTable reads rows of a timestamp followed by one or more values. The
+// delimiter is detected from the first line when comma is 0. The first row
+// is a header if its first field is not a timestamp.
+func readTable(r io.Reader, comma rune, timefmt string) (*table, error) {
+	br := bufio.NewReader(r)
+	if comma == 0 {
+		comma = ','
+		if line, _ := br.Peek(4096); bytes.ContainsRune(firstLine(line), '\t') {
+			comma = '\t'
+		}
+	}
+	cr := csv.NewReader(br)
+	cr.Comma = comma
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	recs, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("no rows")
+	}
+
+	t := &table{}
+	if _, err := parseTime(recs[0][0], Auto); err != nil {
+		t.titles = recs[0][1:]
+		recs = recs[1:]
+	}
+	if timefmt == Auto {
+		timefmt = detectTime(recs)
+	}
+	for i, rec := range recs {
+		line := i + 1
+		if t.titles != nil {
+			line++
+		}
+		ts, err := parseTime(rec[0], timefmt)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		vals := make([]float64, len(rec)-1)
+		for j, f := range rec[1:] {
+			if vals[j], err = parseValue(f); err != nil {
+				return nil, fmt.Errorf("line %d, column %d: %v", line, j+2, err)
+			}
+		}
+		t.times = append(t.times, ts)
+		t.rows = append(t.rows, vals)
+	}
+	if len(t.rows) == 0 {
+		return nil, fmt.Errorf("no rows")
+	}
+	return t, nil
+}
+
+func firstLine(b []byte) []byte {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i]
+	}
+	return b
+}
+
+// detectTime returns the timestamp format of the rows, numeric if the first
+// row is numeric. Epochs after 1973-03-03 in milliseconds are larger than
+// 1e11, which is in the year 5138 in seconds.
+func detectTime(recs [][]string) string {
+	if len(recs) == 0 {
+		return Seconds
+	}
+	if _, err := strconv.ParseFloat(recs[0][0], 64); err != nil {
+		return RFC3339
+	}
+	for _, rec := range recs {
+		if v, err := strconv.ParseFloat(rec[0], 64); err == nil && math.Abs(v) >= 1e11 {
+			return Millis
+		}
+	}
+	return Seconds
+}
+
+// parseTime returns the timestamp s in epoch milliseconds.
+func parseTime(s, format string) (int64, error) {
+	switch format {
+	case Seconds, Millis:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, fmt.Errorf("invalid epoch %q", s)
+		}
+		if format == Seconds {
+			v *= 1000
+		}
+		return int64(math.Round(v)), nil
+	case RFC3339:
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid RFC3339 time %q", s)
+		}
+		return tm.UnixNano() / int64(time.Millisecond), nil
+	case Auto:
+		if ts, err := parseTime(s, Seconds); err == nil {
+			return ts, nil
+		}
+		if ts, err := parseTime(s, RFC3339); err == nil {
+			return ts, nil
+		}
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return 0, fmt.Errorf("unknown time format %q", format)
+}
+
+// parseValue parses a value, empty fields, null and - are missing values.
+func parseValue(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "", "-", "null", "nan":
+		return math.NaN(), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// series are the resampled values of all tables.
+type series struct {
+	titles     []string
+	values     [][]float64
+	start, end int64 // epoch seconds
+}
+
+// resample merges the columns of all tables onto a single time grid. The
+// interval is the smallest median interval of the tables, values in the
+// same interval are averaged and intervals without values are missing.
+func resample(tables []*table) *series {
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	step := int64(0)
+	for _, t := range tables {
+		sort.Stable(byTime{t})
+		if t.times[0] < first {
+			first = t.times[0]
+		}
+		if l := t.times[len(t.times)-1]; l > last {
+			last = l
+		}
+		if s := medianStep(t.times); s > 0 && (step == 0 || s < step) {
+			step = s
+		}
+	}
+	if step == 0 {
+		step = 1000
+	}
+	if (last-first)/step+1 > maxPoints {
+		step = (last-first)/(maxPoints-1) + 1
+	}
+	n := int((last-first)/step) + 1
+
+	s := &series{start: first / 1000, end: (first + int64(n)*step) / 1000}
+	if s.end == s.start {
+		s.end++
+	}
+	for _, t := range tables {
+		cols := 0
+		for _, r := range t.rows {
+			if len(r) > cols {
+				cols = len(r)
+			}
+		}
+		for c := 0; c < cols; c++ {
+			sum, cnt := make([]float64, n), make([]int, n)
+			for i, r := range t.rows {
+				if c >= len(r) || math.IsNaN(r[c]) {
+					continue
+				}
+				x := int((t.times[i] - first) / step)
+				sum[x] += r[c]
+				cnt[x]++
+			}
+			for x := range sum {
+				if cnt[x] == 0 {
+					sum[x] = math.NaN()
+					continue
+				}
+				sum[x] /= float64(cnt[x])
+			}
+			title := ""
+			if c < len(t.titles) {
+				title = t.titles[c]
+			}
+			s.titles = append(s.titles, title)
+			s.values = append(s.values, sum)
+		}
+	}
+	return s
+}
+
+// medianStep returns the median positive interval of the sorted times.
+func medianStep(times []int64) int64 {
+	var d []int64
+	for i := 1; i < len(times); i++ {
+		if x := times[i] - times[i-1]; x > 0 {
+			d = append(d, x)
+		}
+	}
+	if len(d) == 0 {
+		return 0
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	return d[len(d)/2]
+}
+
+// byTime sorts the rows of a table by time.
+type byTime struct{ *table }
+
+func (t byTime) Len() int           { return len(t.times) }
+func (t byTime) Less(i, j int) bool { return t.times[i] < t.times[j] }
+func (t byTime) Swap(i, j int) {
+	t.times[i], t.times[j] = t.times[j], t.times[i]
+	t.rows[i], t.rows[j] = t.rows[j], t.rows[i]
+}
@@ -0,0 +1,440 @@
+// Package config creates charts from declarative json or yaml definitions,
+// so charts can be defined without recompiling. For example:
+//
+//	title: Traffic
+//	size: big
+//	start: -24h
+//	axes:
+//	  y: {format: si, base: 1024, ticks: 4, grid: 2}
+//	legend: {position: right, stats: [max, avg, p95]}
+//	series:
+//	  - {title: in, source: eth0.rx, unit: B/s}
+//	  - {title: out, source: eth0.tx, unit: B/s, smooth: {method: wma, window: 16}}
+//
+// The values of each series are read from the source referenced by the
+// series when the chart is created. Errors point to the offending field,
+// e.g. "series[1].smooth.method: unknown smoothing method".
+package config
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tomarus/chart"
+	"github.com/tomarus/chart/axis"
+	"github.com/tomarus/chart/canvas"
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/pdf"
+	"github.com/tomarus/chart/png"
+	"github.com/tomarus/chart/spec"
+	"github.com/tomarus/chart/svg"
+)
+
+// Definition is a declarative chart definition. The fields correspond to
+// chart.Options.
+type Definition struct {
+	Title     string    `json:"title"`
+	Image     string    `json:"image"` // "svg" (default), "png", "canvas", "pdf", "spec" or a name in Images
+	Size      string    `json:"size"`  // "big", "small" or "auto"
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Scale     float64   `json:"scale"`
+	Scheme    string    `json:"scheme"`
+	Theme     string    `json:"theme"`
+	Start     Time      `json:"start"` // epoch, RFC3339, "now" or a duration relative to now, "-24h" by default
+	End       Time      `json:"end"`   // same as Start, "now" by default
+	SIBase    int       `json:"sibase"`
+	MarginX   int       `json:"marginx"`
+	MarginY   int       `json:"marginy"`
+	TitleFont *Font     `json:"titleFont"`
+	LabelFont *Font     `json:"labelFont"`
+	Axes      *Axes     `json:"axes"`
+	Legend    *Legend   `json:"legend"`
+	Series    []*Series `json:"series"`
+	now       func() time.Time
+}
+
+// Time is a point in time, see Definition.Start.
+type Time string
+
+// Font is the font of the title or the labels.
+type Font struct {
+	Family string  `json:"family"`
+	Size   float64 `json:"size"`
+	Weight string  `json:"weight"`
+	File   string  `json:"file"`
+}
+
+// Axes are the X and Y axis. Both must be set to override the default axes.
+type Axes struct {
+	X *Axis `json:"x"`
+	Y *Axis `json:"y"`
+}
+
+// Axis defines an axis and the name of its label formatter.
+type Axis struct {
+	Format   string `json:"format"`   // "time" (default for x), "si" (default for y), "float" or a name in Formatters
+	Layout   string `json:"layout"`   // Go time layout of the time formatter, e.g. "15:04"
+	Base     int    `json:"base"`     // SI base of the si formatter, the chart sibase by default
+	Decimals int    `json:"decimals"` // decimals of the float formatter
+	Unit     string `json:"unit"`
+	Ticks    int    `json:"ticks"`
+	Duration string `json:"duration"` // time between ticks, e.g. "4h", instead of Ticks
+	Grid     int    `json:"grid"`
+	Center   bool   `json:"center"`
+}
+
+// Legend defines the legend.
+type Legend struct {
+	Position string   `json:"position"`
+	Columns  int      `json:"columns"`
+	Stats    []string `json:"stats"`
+}
+
+// Series defines a dataset. The fields correspond to data.Options.
+type Series struct {
+	Title          string  `json:"title"`
+	Source         string  `json:"source"` // reference passed to the Sources
+	Type           string  `json:"type"`   // "area" (default) or "line"
+	Unit           string  `json:"unit"`
+	Gap            float64 `json:"gap"`
+	Smooth         *Smooth `json:"smooth"`
+	Envelope       bool    `json:"envelope"`
+	Downsample     string  `json:"downsample"`
+	Percentile     float64 `json:"percentile"`
+	Upsample       string  `json:"upsample"`
+	PercentileLine float64 `json:"percentileLine"`
+}
+
+// Smooth defines the smoothed overlay of a series.
+type Smooth struct {
+	Method string  `json:"method"`
+	Window int     `json:"window"`
+	Min    float64 `json:"min"`
+}
+
+// Formatters are additional axis label formatters by name.
+var Formatters = map[string]axis.Formatter{}
+
+// Images are additional chart images by name, e.g. terminal images which
+// need a size in characters.
+var Images = map[string]func() image.Image{}
+
+// Sources returns the values of the data source referenced by a series.
+type Sources interface {
+	Values(ref string) ([]float64, error)
+}
+
+// Values are Sources of fixed values by reference.
+type Values map[string][]float64
+
+// Values returns the values of ref.
+func (v Values) Values(ref string) ([]float64, error) {
+	vals, ok := v[ref]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", ref)
+	}
+	return vals, nil
+}
+
+// Parse parses and validates a json or yaml definition.
+func Parse(b []byte) (*Definition, error) {
+	var in interface{}
+	if err := yaml.Unmarshal(b, &in); err != nil {
+		return nil, err
+	}
+	d := &Definition{}
+	if err := decode("", in, d); err != nil {
+		return nil, err
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Validate checks all fields of the definition.
+func (d *Definition) Validate() error {
+	if _, err := newImage(d.Image); err != nil {
+		return errorf("image", "%v", err)
+	}
+	switch d.Size {
+	case "", "big", "small", "auto":
+	default:
+		return errorf("size", "unknown size %q", d.Size)
+	}
+	if d.Width < 0 {
+		return errorf("width", "must not be negative")
+	}
+	if d.Height < 0 {
+		return errorf("height", "must not be negative")
+	}
+	if d.Scale < 0 {
+		return errorf("scale", "must not be negative")
+	}
+	switch d.SIBase {
+	case 0, 1000, 1024:
+	default:
+		return errorf("sibase", "must be 1000 or 1024, got %d", d.SIBase)
+	}
+	now := time.Now()
+	if _, err := d.Start.Unix(now); err != nil {
+		return errorf("start", "%v", err)
+	}
+	if _, err := d.End.Unix(now); err != nil {
+		return errorf("end", "%v", err)
+	}
+	if a := d.Axes; a != nil {
+		if a.X == nil || a.Y == nil {
+			return errorf("axes", "both x and y must be set")
+		}
+		if _, err := a.X.axis(axis.Bottom, d.SIBase); err != nil {
+			return err.(*Error).in("axes.x")
+		}
+		if _, err := a.Y.axis(axis.Left, d.SIBase); err != nil {
+			return err.(*Error).in("axes.y")
+		}
+	}
+	if l := d.Legend; l != nil {
+		switch l.Position {
+		case "", image.LegendBottom, image.LegendTop, image.LegendRight, image.LegendHidden:
+		default:
+			return errorf("legend.position", "unknown legend position %q", l.Position)
+		}
+		for i, st := range l.Stats {
+			if !data.ValidStat(st) {
+				return errorf(fmt.Sprintf("legend.stats[%d]", i), "unknown legend statistic %q", st)
+			}
+		}
+	}
+	if len(d.Series) == 0 {
+		return errorf("series", "at least one series is required")
+	}
+	for i, s := range d.Series {
+		if s == nil {
+			return errorf(fmt.Sprintf("series[%d]", i), "missing series")
+		}
+		if err := s.validate(); err != nil {
+			return err.in(fmt.Sprintf("series[%d]", i))
+		}
+	}
+	return nil
+}
+
+func (s *Series) validate() *Error {
+	if s.Source == "" {
+		return errorf("source", "missing source")
+	}
+	switch s.Type {
+	case "", "area", "line":
+	default:
+		return errorf("type", "unknown type %q", s.Type)
+	}
+	switch s.Downsample {
+	case "", "lttb", "avg", "sum", "min", "max", "first", "last", "m4", "percentile":
+	default:
+		return errorf("downsample", "unknown downsample method %q", s.Downsample)
+	}
+	switch s.Upsample {
+	case "", "bars", "step", "linear":
+	default:
+		return errorf("upsample", "unknown upsample method %q", s.Upsample)
+	}
+	if s.Percentile < 0 || s.Percentile > 100 {
+		return errorf("percentile", "must be between 0 and 100")
+	}
+	if s.Downsample == "percentile" && s.Percentile == 0 {
+		return errorf("percentile", "required by the percentile downsample method")
+	}
+	if s.PercentileLine < 0 || s.PercentileLine > 100 {
+		return errorf("percentileLine", "must be between 0 and 100")
+	}
+	if s.Smooth != nil {
+		switch data.Smoother(s.Smooth.Method) {
+		case data.SMA, data.WMA, data.EMA, data.Median:
+		default:
+			return errorf("smooth.method", "unknown smoothing method %q", s.Smooth.Method)
+		}
+		if s.Smooth.Window < 1 {
+			return errorf("smooth.window", "must be at least 1")
+		}
+	}
+	return nil
+}
+
+// Chart creates the chart, which is rendered to w, and adds the values of
+// each series read from src.
+func (d *Definition) Chart(w io.Writer, src Sources) (*chart.Chart, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if d.now != nil {
+		now = d.now()
+	}
+	from := d.Start
+	if from == "" {
+		from = "-24h"
+	}
+	start, _ := from.Unix(now)
+	end, _ := d.End.Unix(now)
+	img, _ := newImage(d.Image)
+	o := &chart.Options{
+		Title:   d.Title,
+		Image:   img,
+		W:       w,
+		Size:    d.Size,
+		Width:   d.Width,
+		Height:  d.Height,
+		Scale:   d.Scale,
+		Scheme:  d.Scheme,
+		Theme:   d.Theme,
+		Start:   start,
+		End:     end,
+		SIBase:  d.SIBase,
+		MarginX: d.MarginX,
+		MarginY: d.MarginY,
+	}
+	if d.TitleFont != nil {
+		o.TitleFont = image.Font(*d.TitleFont)
+	}
+	if d.LabelFont != nil {
+		o.LabelFont = image.Font(*d.LabelFont)
+	}
+	if d.Axes != nil {
+		x, _ := d.Axes.X.axis(axis.Bottom, d.SIBase)
+		y, _ := d.Axes.Y.axis(axis.Left, d.SIBase)
+		o.Axes = []*axis.Axis{x, y}
+	}
+	if d.Legend != nil {
+		o.LegendPosition = d.Legend.Position
+		o.LegendColumns = d.Legend.Columns
+		o.Legend = d.Legend.Stats
+	}
+	c, err := chart.NewChart(o)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range d.Series {
+		vals, err := src.Values(s.Source)
+		if err != nil {
+			return nil, errorf(fmt.Sprintf("series[%d].source", i), "%v", err)
+		}
+		opt := &data.Options{Title: s.Title, Type: s.Type, Unit: s.Unit, Gap: s.Gap, Envelope: s.Envelope,
+			Downsample: s.Downsample, Percentile: s.Percentile, Upsample: s.Upsample, PercentileLine: s.PercentileLine}
+		if s.Smooth != nil {
+			opt.Smooth = data.Smoothing{Method: data.Smoother(s.Smooth.Method), Window: s.Smooth.Window, Min: s.Smooth.Min}
+		}
+		if err := c.AddData(opt, vals); err != nil {
+			return nil, errorf(fmt.Sprintf("series[%d].source", i), "%v", err)
+		}
+	}
+	return c, nil
+}
+
+// newImage returns the chart image by name.
+func newImage(name string) (image.Image, error) {
+	switch name {
+	case "", "svg":
+		return svg.New(), nil
+	case "png":
+		return png.New(), nil
+	case "canvas":
+		return canvas.New(), nil
+	case "pdf":
+		return pdf.New(), nil
+	case "spec":
+		return spec.New(), nil
+	}
+	if f, ok := Images[name]; ok {
+		return f(), nil
+	}
+	return nil, fmt.Errorf("unknown image %q", name)
+}
+
+// axis returns the axis at position p.
+func (a *Axis) axis(p axis.Position, sibase int) (*axis.Axis, error) {
+	name := a.Format
+	if name == "" {
+		name = "si"
+		if p == axis.Bottom {
+			name = "time"
+		}
+	}
+	var ax *axis.Axis
+	switch name {
+	case "time":
+		layout := a.Layout
+		if layout == "" {
+			layout = "15:04"
+		}
+		ax = axis.NewTime(p, layout)
+	case "si":
+		base := a.Base
+		if base == 0 {
+			base = sibase
+		}
+		if base == 0 {
+			base = 1000
+		}
+		ax = axis.NewSI(p, base)
+	case "float":
+		if a.Decimals < 0 {
+			return nil, errorf("decimals", "must not be negative")
+		}
+		ax = axis.NewFloat(p, a.Decimals)
+	default:
+		f, ok := Formatters[name]
+		if !ok {
+			return nil, errorf("format", "unknown formatter %q", name)
+		}
+		ax = axis.New(p, f)
+	}
+	if a.Duration != "" {
+		dur, err := time.ParseDuration(a.Duration)
+		if err != nil || dur <= 0 {
+			return nil, errorf("duration", "invalid duration %q", a.Duration)
+		}
+		ax.Duration(dur)
+	} else {
+		if a.Ticks < 0 {
+			return nil, errorf("ticks", "must not be negative")
+		}
+		ticks := a.Ticks
+		if ticks == 0 {
+			ticks = 4
+		}
+		ax.Ticks(ticks)
+	}
+	if a.Grid < 0 {
+		return nil, errorf("grid", "must not be negative")
+	}
+	ax.Grid(a.Grid).Unit(a.Unit)
+	if a.Center {
+		ax.Center()
+	}
+	return ax, nil
+}
+
+// Unix returns t in seconds since the epoch, durations are relative to now.
+func (t Time) Unix(now time.Time) (int64, error) {
+	switch t {
+	case "", "now":
+		return now.Unix(), nil
+	}
+	if n, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+		return n, nil
+	}
+	if tm, err := time.Parse(time.RFC3339, string(t)); err == nil {
+		return tm.Unix(), nil
+	}
+	if d, err := time.ParseDuration(string(t)); err == nil {
+		return now.Add(d).Unix(), nil
+	}
+	return 0, fmt.Errorf("invalid time %q, use an epoch, RFC3339, now or a duration like -24h", string(t))
+}
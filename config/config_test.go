@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tomarus/chart/spec"
+)
+
+const testYAML = `
+title: Traffic
+image: spec
+width: 200
+height: 100
+start: -1h
+axes:
+  x: {layout: "15:04", duration: 15m, grid: 3}
+  y: {format: float, decimals: 1, unit: " B", ticks: 2}
+legend: {position: right, stats: [max, p95]}
+series:
+  - {title: in, source: rx}
+  - title: out
+    source: tx
+    smooth: {method: wma, window: 4}
+`
+
+func TestChart(t *testing.T) {
+	for _, def := range []string{testYAML, `{"image": "spec", "width": 200, "height": 100, "start": 1700000000, "end": "2023-11-14T23:13:20Z", "series": [{"source": "rx"}]}`} {
+		d, err := Parse([]byte(def))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.now = func() time.Time { return time.Unix(1700000000, 0) }
+		var out bytes.Buffer
+		c, err := d.Chart(&out, Values{"rx": {1, 2, 3, 4}, "tx": {4, 3, 2, 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Render(); err != nil {
+			t.Fatal(err)
+		}
+		var doc spec.Chart
+		if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Area.Width != 200 || len(doc.Series) != len(d.Series) {
+			t.Errorf("Unexpected chart %+v", doc.Area)
+		}
+		switch d.Title {
+		case "Traffic":
+			if doc.Start != 1700000000-3600 || doc.End != 1700000000 {
+				t.Errorf("Expected the last hour, got %d-%d", doc.Start, doc.End)
+			}
+			if y := doc.YAxis.Ticks; len(y) != 3 || y[0].Label != "4.0 B" {
+				t.Errorf("Expected float labels, got %+v", y)
+			}
+			if doc.Legend == nil || doc.Legend.Position != "right" || len(doc.Series[1].Smoothed) == 0 {
+				t.Error("Expected legend and smoothed series")
+			}
+		default:
+			if doc.Start != 1700000000 || doc.End != 1700003600 {
+				t.Errorf("Expected epoch and RFC3339 times, got %d-%d", doc.Start, doc.End)
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for def, path := range map[string]string{
+		`{"series": [{"source": "a", "colour": "red"}]}`:                              "series[0].colour: unknown field",
+		`{"width": "wide", "series": [{"source": "a"}]}`:                              `width: expected an integer, got "wide"`,
+		`{"width": 1.5, "series": [{"source": "a"}]}`:                                 "width: expected an integer, got 1.5",
+		`{"width": -1, "series": [{"source": "a"}]}`:                                  "width: must not be negative",
+		`{"height": -100, "series": [{"source": "a"}]}`:                               "height: must not be negative",
+		`{"scale": -2, "series": [{"source": "a"}]}`:                                  "scale: must not be negative",
+		`{"sibase": 100, "series": [{"source": "a"}]}`:                                "sibase: must be 1000 or 1024, got 100",
+		`{"series": [{"source": "a"}, {"source": "b", "type": 1}]}`:                   "series[1].type: expected a string, got 1",
+		`{"series": [{"source": "a"}, {"smooth": {}}]}`:                               "series[1].source: missing source",
+		`{"series": [{"source": "a", "smooth": {"method": "x"}}]}`:                    `series[0].smooth.method: unknown smoothing method "x"`,
+		`{"series": [{"source": "a", "envelope": "yes"}]}`:                            `series[0].envelope: expected true or false, got "yes"`,
+		`{"series": [{"source": "a", "downsample": "percentile"}]}`:                   "series[0].percentile: required by the percentile downsample method",
+		`{"series": {"source": "a"}}`:                                                 "series: expected a list, got an object",
+		`{"series": []}`:                                                              "series: at least one series is required",
+		`{"legend": {"stats": ["max", "mode"]}, "series": [{"source": "a"}]}`:         `legend.stats[1]: unknown legend statistic "mode"`,
+		`{"axes": {"x": {}, "y": {"format": "hex"}}, "series": [{"source": "a"}]}`:    `axes.y.format: unknown formatter "hex"`,
+		`{"axes": {"x": {"duration": "soon"}, "y": {}}, "series": [{"source": "a"}]}`: `axes.x.duration: invalid duration "soon"`,
+		`{"start": "yesterday", "series": [{"source": "a"}]}`:                         `start: invalid time "yesterday", use an epoch, RFC3339, now or a duration like -24h`,
+		`{"image": "bmp", "series": [{"source": "a"}]}`:                               `image: unknown image "bmp"`,
+	} {
+		_, err := Parse([]byte(def))
+		if err == nil || err.Error() != path {
+			t.Errorf("%s: Expected error %q, got %v", def, path, err)
+		}
+	}
+
+	d, _ := Parse([]byte(`{"series": [{"source": "a"}, {"source": "b"}]}`))
+	if _, err := d.Chart(nil, Values{"a": {1}}); err == nil || err.Error() != `series[1].source: unknown source "b"` {
+		t.Errorf("Expected unknown source error, got %v", err)
+	}
+	if d.Start != "" {
+		t.Errorf("Expected the default start not to change the definition, got %q", d.Start)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q, _ := url.ParseQuery("title=Traffic&width=300&axes.x.grid=2&axes.y.format=float&axes.y.center=true&legend.stats=max,p95&legend.stats=last&target=rx&target=tx&series.title=in&series.smooth.method=wma&series.smooth.window=4")
+	d, err := ParseQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Traffic" || d.Width != 300 || d.Axes.X.Grid != 2 || d.Axes.Y.Format != "float" || !d.Axes.Y.Center {
+		t.Errorf("Unexpected definition %+v", d)
+	}
+	if len(d.Legend.Stats) != 3 || d.Legend.Stats[2] != "last" {
+		t.Errorf("Expected 3 legend stats, got %v", d.Legend.Stats)
+	}
+	if len(d.Series) != 2 || d.Series[0].Title != "in" || d.Series[1].Source != "tx" || d.Series[0].Smooth.Window != 4 || d.Series[1].Smooth != nil {
+		t.Errorf("Unexpected series %+v %+v", d.Series[0], d.Series[1])
+	}
+
+	for query, msg := range map[string]string{
+		"target=rx&width=1&width=2":   "width: expected a single value, got 2",
+		"target=rx&axes.y.center=yes": `axes.y.center: expected true or false, got "yes"`,
+		"target=rx&title.text=x":      "title.text: unknown field",
+		"target=rx&series=x":          "series: missing field name, e.g. series.title",
+	} {
+		q, _ := url.ParseQuery(query)
+		if _, err := ParseQuery(q); err == nil || err.Error() != msg {
+			t.Errorf("%s: Expected %q, got %v", query, msg, err)
+		}
+	}
+}
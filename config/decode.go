@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error is an error of the definition field at Path, e.g. series[1].type.
+type Error struct {
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// errorf returns an Error of the field at path.
+func errorf(path, format string, a ...interface{}) *Error {
+	return &Error{Path: path, Err: fmt.Errorf(format, a...)}
+}
+
+// in prefixes the path of e with the path of its parent.
+func (e *Error) in(parent string) *Error {
+	e.Path = join(parent, e.Path)
+	return e
+}
+
+func join(parent, name string) string {
+	if parent == "" || strings.HasPrefix(name, "[") {
+		return parent + name
+	}
+	return parent + "." + name
+}
+
+// decode stores the value in, as unmarshaled from json or yaml, in the
+// struct, slice, map or basic value v. The field names are the json tags.
+func decode(path string, in interface{}, v interface{}) error {
+	return decodeValue(path, in, reflect.ValueOf(v).Elem())
+}
+
+func decodeValue(path string, in interface{}, v reflect.Value) error {
+	if in == nil {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeValue(path, in, v.Elem())
+
+	case reflect.Struct:
+		m, err := object(path, in)
+		if err != nil {
+			return err
+		}
+		fields := map[string]int{}
+		for i := 0; i < v.NumField(); i++ {
+			if tag := v.Type().Field(i).Tag.Get("json"); tag != "" {
+				fields[tag] = i
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			i, ok := fields[k]
+			if !ok {
+				return errorf(join(path, k), "unknown field")
+			}
+			if err := decodeValue(join(path, k), m[k], v.Field(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		list, ok := in.([]interface{})
+		if !ok {
+			return errorf(path, "expected a list, got %s", kind(in))
+		}
+		s := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i := range list {
+			if err := decodeValue(fmt.Sprintf("%s[%d]", path, i), list[i], s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+
+	case reflect.String:
+		switch x := in.(type) {
+		case string:
+			v.SetString(x)
+		case int:
+			// times may be written as epoch
+			if v.Type() != reflect.TypeOf(Time("")) {
+				return errorf(path, "expected a string, got %s", kind(in))
+			}
+			v.SetString(strconv.Itoa(x))
+		default:
+			return errorf(path, "expected a string, got %s", kind(in))
+		}
+
+	case reflect.Int:
+		f, ok := number(in)
+		if !ok || f != math.Trunc(f) {
+			return errorf(path, "expected an integer, got %s", kind(in))
+		}
+		v.SetInt(int64(f))
+
+	case reflect.Float64:
+		f, ok := number(in)
+		if !ok {
+			return errorf(path, "expected a number, got %s", kind(in))
+		}
+		v.SetFloat(f)
+
+	case reflect.Bool:
+		b, ok := in.(bool)
+		if !ok {
+			return errorf(path, "expected true or false, got %s", kind(in))
+		}
+		v.SetBool(b)
+	}
+	return nil
+}
+
+// object returns in as an object with string keys.
+func object(path string, in interface{}) (map[string]interface{}, error) {
+	switch m := in.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			s, ok := k.(string)
+			if b, isbool := k.(bool); isbool && b {
+				// yaml 1.1 reads the key y as true
+				s, ok = "y", true
+			}
+			if !ok {
+				return nil, errorf(path, "expected string keys, got %v", k)
+			}
+			res[s] = v
+		}
+		return res, nil
+	}
+	return nil, errorf(path, "expected an object, got %s", kind(in))
+}
+
+// number returns in as float64.
+func number(in interface{}) (float64, bool) {
+	switch x := in.(type) {
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+// kind describes the type of in for errors.
+func kind(in interface{}) string {
+	switch x := in.(type) {
+	case string:
+		return strconv.Quote(x)
+	case int, int64, float64:
+		return fmt.Sprint(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", in)
+}
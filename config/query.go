@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseQuery parses and validates a definition from url query parameters.
+// The parameter names are the field paths, e.g. axes.y.format=si. Repeated
+// series fields set consecutive series, target is short for series.source:
+//
+//	?title=Traffic&legend.stats=max,p95&target=eth0.rx&target=eth0.tx&series.title=in&series.title=out
+//
+// Lists, like legend.stats, are comma separated.
+func ParseQuery(q url.Values) (*Definition, error) {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	in := map[string]interface{}{}
+	for _, k := range keys {
+		path := k
+		if k == "target" {
+			path = "series.source"
+		}
+		if err := query(in, reflect.TypeOf(Definition{}), "", strings.Split(path, "."), q[k]); err != nil {
+			return nil, err
+		}
+	}
+	d := &Definition{}
+	if err := decode("", in, d); err != nil {
+		return nil, err
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// query stores the values vals of the field path names of the struct type t
+// in the object m, as if unmarshaled from json.
+func query(m map[string]interface{}, t reflect.Type, parent string, names []string, vals []string) error {
+	name := names[0]
+	path := join(parent, name)
+	var ft reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("json") == name {
+			ft = t.Field(i).Type
+		}
+	}
+	if ft == nil {
+		return errorf(path, "unknown field")
+	}
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	elem := ft
+	if ft.Kind() == reflect.Slice {
+		for elem = ft.Elem(); elem.Kind() == reflect.Ptr; elem = elem.Elem() {
+		}
+	}
+
+	switch {
+	case elem.Kind() == reflect.Struct && len(names) == 1:
+		return errorf(path, "missing field name, e.g. %s.%s", path, elem.Field(0).Tag.Get("json"))
+
+	case ft.Kind() == reflect.Struct:
+		sub, ok := m[name].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			m[name] = sub
+		}
+		return query(sub, ft, path, names[1:], vals)
+
+	case ft.Kind() == reflect.Slice && elem.Kind() == reflect.Struct:
+		list, _ := m[name].([]interface{})
+		for len(list) < len(vals) {
+			list = append(list, map[string]interface{}{})
+		}
+		m[name] = list
+		for i, v := range vals {
+			if err := query(list[i].(map[string]interface{}), elem, fmt.Sprintf("%s[%d]", path, i), names[1:], []string{v}); err != nil {
+				return err
+			}
+		}
+
+	case len(names) > 1:
+		return errorf(join(path, names[1]), "unknown field")
+
+	case ft.Kind() == reflect.Slice:
+		var list []interface{}
+		for _, v := range vals {
+			for _, s := range strings.Split(v, ",") {
+				list = append(list, queryValue(elem.Kind(), s))
+			}
+		}
+		m[name] = list
+
+	default:
+		if len(vals) > 1 {
+			return errorf(path, "expected a single value, got %d", len(vals))
+		}
+		m[name] = queryValue(ft.Kind(), vals[0])
+	}
+	return nil
+}
+
+// queryValue converts the query value s to kind. Invalid values are kept as
+// string, decode reports these.
+func queryValue(kind reflect.Kind, s string) interface{} {
+	switch kind {
+	case reflect.Int, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
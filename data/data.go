@@ -1,15 +1,38 @@
 package data
 
+import (
+	"fmt"
+	"math"
+)
+
+// Missing is the normalized pixel value used for missing (NaN) values.
+const Missing = math.MinInt32
+
 // Data contains a single set of data most likely imported from tsm.
 type Data struct {
+	src    []float64 ``              // source values, before resampling
 	raw    []float64 ``              // raw values
+	low    []float64 ``              // per pixel minimum when using an envelope
+	high   []float64 ``              // per pixel maximum when using an envelope
 	gap    float64   ``              // gap in % between bar chart values
+	env    bool      ``              // keep min/max envelope when downsampling
+	down   string    ``              // downsample method
+	up     string    ``              // upsample method
+	pct    float64   ``              // percentile used by the percentile downsampler
+	pline  float64   ``              // percentile line
 	Max    float64   `json:"fmax"`   // max raw value
 	NMax   int       `json:"max"`    // max normalized value
 	Scale  []string  `json:"scale"`  // yaxis labels
 	Values []int     `json:"values"` // pixel values
 	Type   string    `json:"type"`
 	Title  string    `json:"title"`
+	Unit   string    `json:"unit,omitempty"`
+
+	Smoothing Smoothing `json:"smooth"`             // smoothed overlay configuration
+	Smoothed  []int     `json:"smoothed,omitempty"` // smoothed overlay pixel values
+
+	Low  []int `json:"low,omitempty"`  // envelope minimum pixel values
+	High []int `json:"high,omitempty"` // envelope maximum pixel values
 }
 
 // Options contains configuration for a single dataset.
@@ -21,17 +44,75 @@ type Options struct {
 	// Title to display on top of the chart.
 	Title string
 
+	// Unit of the values, e.g. "B/s", "%" or "ms". The unit is appended to
+	// the values shown in the legend, the Y axis and the svg readout.
+	Unit string
+
 	// Gap is the % of space between bar charts, of the number of datapoints
 	// supplied is smaller than the chart width. I.e. plotting 30 values with
 	// a chart width of 300 and a Gap of 0.1 plots 30 individual bar chart
 	// value with a thickness of 24px with 2 * 10% (left & right) space in between.
 	// By default the Gap is 0.00.
 	Gap float64
+
+	// Smooth draws a smoothed overlay line on top of the dataset, e.g.
+	// Smoothing{Method: data.WMA, Window: 16}. By default no overlay is drawn.
+	Smooth Smoothing
+
+	// Envelope keeps the minimum, maximum and average value of each pixel
+	// when the data is downsampled. The average is plotted as a line on top
+	// of a shaded min-max band, so short peaks remain visible.
+	Envelope bool
+
+	// Downsample selects how values are combined when there are more values
+	// than pixels. By default the Largest Triangle Three Buckets algorithm
+	// "lttb" is used. Other methods take all values within a pixel and use
+	// their average ("avg"), total ("sum"), "min", "max", "first", "last"
+	// or percentile ("percentile", see Percentile). Use "sum" for counters
+	// which must keep their totals and "avg" or "max" for gauges.
+	// "m4" keeps the min and max value of each pixel as envelope and plots
+	// the last value on top of it. Unknown methods are rejected by Validate.
+	Downsample string
+
+	// Percentile is the percentile (0-100) of each pixel used by the
+	// "percentile" downsample method, which requires a percentile above 0.
+	Percentile float64
+
+	// Upsample selects how values are stretched when there are less values
+	// than pixels. "bars" (the default) repeats values using Gap, "step"
+	// repeats values ignoring Gap and "linear" interpolates between values.
+	Upsample string
+
+	// PercentileLine draws a labeled horizontal line at this percentile
+	// (0-100) of the source data, e.g. 95 for 95th percentile billing.
+	PercentileLine float64
+}
+
+// Validate returns an error if the downsample or upsample method is unknown
+// or the percentile of the "percentile" downsample method is missing.
+func (o *Options) Validate() error {
+	switch o.Downsample {
+	case "", "lttb", "avg", "sum", "min", "max", "first", "last", "m4":
+	case "percentile":
+		if o.Percentile <= 0 || o.Percentile > 100 {
+			return fmt.Errorf("percentile downsampling requires a percentile between 0 and 100, got %g", o.Percentile)
+		}
+	default:
+		return fmt.Errorf("unknown downsample method %q", o.Downsample)
+	}
+	switch o.Upsample {
+	case "", "bars", "step", "linear":
+	default:
+		return fmt.Errorf("unknown upsample method %q", o.Upsample)
+	}
+	return nil
 }
 
 // NewData creates a new dataset from []float64.
 func NewData(opt *Options, in []float64) Data {
-	return Data{Type: opt.Type, Title: opt.Title, gap: opt.Gap, raw: in}
+	return Data{Type: opt.Type, Title: opt.Title, Unit: opt.Unit, gap: opt.Gap, src: in, raw: in, env: opt.Envelope,
+		down: opt.Downsample, up: opt.Upsample, pct: opt.Percentile, pline: opt.PercentileLine,
+		Smoothing: opt.Smooth}
 }
 
 // Len returns the number of items in the dataset.
@@ -39,22 +120,63 @@ func (d *Data) Len() int {
 	return len(d.raw)
 }
 
-// MinMaxAvg returns the Minimum, Maximum and Average values of the raw data.
+// Raw returns the raw, possibly resampled, values of the dataset.
+func (d *Data) Raw() []float64 {
+	return d.raw
+}
+
+// Source returns the values of the dataset before it was resampled.
+func (d *Data) Source() []float64 {
+	return d.src
+}
+
+// Window returns a new dataset with the same options containing the source
+// values from up to, but not including, to.
+func (d *Data) Window(from, to int) Data {
+	return Data{Type: d.Type, Title: d.Title, Unit: d.Unit, gap: d.gap, src: d.src[from:to], raw: d.src[from:to], env: d.env,
+		down: d.down, up: d.up, pct: d.pct, pline: d.pline, Smoothing: d.Smoothing}
+}
+
+// PercentileLine returns the percentile for which a line should be drawn, 0 if none.
+func (d *Data) PercentileLine() float64 {
+	return d.pline
+}
+
+// MinMaxAvg returns the Minimum, Maximum and Average values of the source data,
+// before it was resampled. NaN values are ignored. When the data was
+// downsampled using "sum" the resampled values are used, as the sums of the
+// pixels exceed the source values.
 func (d *Data) MinMaxAvg() (float64, float64, float64) {
+	vals := d.src
+	if d.down == "sum" && len(d.raw) < len(d.src) {
+		vals = d.raw
+	}
+	min, max, avg := minMaxAvg(vals)
+	d.Max = max
+	return min, max, avg
+}
+
+// minMaxAvg returns the smallest non zero, largest and average value of v,
+// ignoring NaN values. The maximum is at least 0.
+func minMaxAvg(v []float64) (float64, float64, float64) {
 	max := 0.
 	avg := 0.
 	min := 0.
-	for _, v := range d.raw {
-		if max < v {
-			max = v
+	n := 0
+	for _, x := range v {
+		if math.IsNaN(x) {
+			continue
+		}
+		n++
+		if max < x {
+			max = x
 		}
-		if v != 0 && (min == 0 || min > v) {
-			min = v
+		if x != 0 && (min == 0 || min > x) {
+			min = x
 		}
-		avg += v
+		avg += x
 	}
-	avg /= float64(len(d.raw))
-	d.Max = max
+	avg /= float64(n)
 	return min, max, avg
 }
 
@@ -62,22 +184,36 @@ func (d *Data) MinMaxAvg() (float64, float64, float64) {
 func (d *Data) normalize(height int) {
 	_, d.Max, _ = d.MinMaxAvg()
 
-	if d.Max == 0 {
-		// we have an empty dataset
-		for _, v := range d.raw {
-			d.Values = append(d.Values, int(v))
-		}
-		return
-	}
-
 	fmax := float64(height)
 	a := fmax / d.Max
 	b := fmax - a*d.Max
+	if d.Max == 0 {
+		// we have an empty dataset, missing values stay missing
+		a, b = 1, 0
+	}
 
 	for _, v := range d.raw {
-		newv := a*v + b
-		d.Values = append(d.Values, int(newv))
+		d.Values = append(d.Values, pixel(v, a, b))
+	}
+
+	for i := range d.low {
+		d.Low = append(d.Low, pixel(d.low[i], a, b))
+		d.High = append(d.High, pixel(d.high[i], a, b))
+	}
+
+	if d.Smoothing.Method != "" {
+		for _, v := range Smooth(d.raw, d.Smoothing) {
+			d.Smoothed = append(d.Smoothed, pixel(v, a, b))
+		}
+	}
+}
+
+// pixel normalizes v using a*v+b. NaN values result in Missing.
+func pixel(v, a, b float64) int {
+	if math.IsNaN(v) {
+		return Missing
 	}
+	return int(a*v + b)
 }
 
 // normalizeMax normalizes our max value according to height and a global max value.
@@ -1,14 +1,16 @@
 package data
 
 import (
+	"math"
 	"sort"
 	"testing"
+	"time"
 )
 
 var testData = Collection{
-	NewData(&Options{"line", "", .05}, []float64{1, 2, 3, 4, 5}),
-	NewData(&Options{"area", "", .05}, []float64{10, 20, 30, 40, 50}),
-	NewData(&Options{"area", "", .05}, []float64{-1, -2, -3, -4, -5}),
+	NewData(&Options{Type: "line", Gap: .05}, []float64{1, 2, 3, 4, 5}),
+	NewData(&Options{Type: "area", Gap: .05}, []float64{10, 20, 30, 40, 50}),
+	NewData(&Options{Type: "area", Gap: .05}, []float64{-1, -2, -3, -4, -5}),
 }
 
 func eq(a, b []int) bool {
@@ -53,6 +55,18 @@ func TestLen(t *testing.T) {
 	}
 }
 
+func TestWindow(t *testing.T) {
+	d := NewData(&Options{Title: "window", Downsample: "max"}, []float64{1, 2, 3, 4, 5, 6})
+	d.Resample(3)
+	w := d.Window(2, 5)
+	if expect := []float64{3, 4, 5}; !feq(w.Raw(), expect) || !feq(w.Source(), expect) {
+		t.Errorf("Expected %#v got %#v", expect, w.Raw())
+	}
+	if w.Title != "window" || w.down != "max" {
+		t.Error("Window should keep the dataset options")
+	}
+}
+
 func TestNormalize(t *testing.T) {
 	testData[0].normalize(10)
 	expect := []int{2, 4, 6, 8, 10}
@@ -79,7 +93,7 @@ func TestNormalizeMax(t *testing.T) {
 
 func TestNormalizeZeros(t *testing.T) {
 	expect := []int{0, 0, 0, 0, 0}
-	td := NewData(&Options{"line", "", .05}, []float64{0, 0, 0, 0, 0})
+	td := NewData(&Options{Type: "line", Gap: .05}, []float64{0, 0, 0, 0, 0})
 	td.normalize(10)
 	if !eq(td.Values, expect) {
 		t.Errorf("Expected %#v got %#v", expect, td.Values)
@@ -105,7 +119,7 @@ func TestSort(t *testing.T) {
 }
 
 func TestStretch(t *testing.T) {
-	data := NewData(&Options{"line", "", .0}, []float64{1, 2, 3, 4, 5})
+	data := NewData(&Options{Type: "line", Gap: .0}, []float64{1, 2, 3, 4, 5})
 	expect := []float64{1, 1, 2, 2, 3, 3, 4, 4, 5, 5}
 	data.Resample(10)
 	if !feq(data.raw, expect) {
@@ -115,13 +129,13 @@ func TestStretch(t *testing.T) {
 
 func TestLTTB(t *testing.T) {
 	// XXX need some scientific testdata for this
-	data := NewData(&Options{"line", "", .05}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	data := NewData(&Options{Type: "line", Gap: .05}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
 	res := data.lttb(10)
 	if !feq(res, data.raw) {
 		t.Error("data should not have changed")
 	}
 
-	data = NewData(&Options{"line", "", .05}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	data = NewData(&Options{Type: "line", Gap: .05}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
 	expect := []float64{1, 2, 6, 9, 10}
 	data.Resample(5)
 	if !feq(data.raw, expect) {
@@ -130,7 +144,7 @@ func TestLTTB(t *testing.T) {
 }
 
 func TestMinMaxAvg(t *testing.T) {
-	data := NewData(&Options{"line", "", .05}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	data := NewData(&Options{Type: "line", Gap: .05}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
 	m, x, a := data.MinMaxAvg()
 	if m != 1 {
 		t.Error("min should be 1")
@@ -142,3 +156,257 @@ func TestMinMaxAvg(t *testing.T) {
 		t.Error("avg should be 5.5")
 	}
 }
+
+func TestSmooth(t *testing.T) {
+	nan := math.NaN()
+	in := []float64{1, 2, 3, 4, 5, nan, 7}
+	var td = []struct {
+		s      Smoothing
+		expect []float64
+	}{
+		{Smoothing{Method: SMA, Window: 3}, []float64{1.5, 2, 3, 4, 4.5, 6, 7}},
+		{Smoothing{Method: WMA, Window: 3}, []float64{4. / 3, 2, 3, 4, 14. / 3, 6, 7}},
+		{Smoothing{Method: EMA, Window: 3}, []float64{1, 1.5, 2.25, 3.125, 4.0625, 4.0625, 5.53125}},
+		{Smoothing{Method: Median, Window: 3}, []float64{1.5, 2, 3, 4, 4.5, 6, 7}},
+		{Smoothing{Method: SMA, Window: 100}, []float64{11. / 3, 11. / 3, 11. / 3, 11. / 3, 11. / 3, 11. / 3, 11. / 3}},
+		{Smoothing{Method: SMA, Window: 3, Min: 3}, []float64{nan, 3, 3.5, 4, 4.5, 6, 7}},
+	}
+	for _, x := range td {
+		res := Smooth(in, x.s)
+		for i := range res {
+			if math.IsNaN(res[i]) != math.IsNaN(x.expect[i]) || math.Abs(res[i]-x.expect[i]) > 1e-9 {
+				t.Errorf("%v: expected %v got %v", x.s, x.expect, res)
+				break
+			}
+		}
+	}
+
+	res := Smooth([]float64{nan, nan, 1}, Smoothing{Method: SMA, Window: 1})
+	if !math.IsNaN(res[0]) || res[2] != 1 {
+		t.Errorf("Expected NaN for windows without values, got %v", res)
+	}
+}
+
+func TestNormalizeSmoothed(t *testing.T) {
+	td := NewData(&Options{Smooth: Smoothing{Method: SMA, Window: 1}}, []float64{0, 5, math.NaN(), 10})
+	td.normalize(10)
+	expect := []int{0, 5, Missing, 10}
+	if !eq(td.Smoothed, expect) {
+		t.Errorf("Expected %#v got %#v", expect, td.Smoothed)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	data := NewData(&Options{Envelope: true}, []float64{1, 9, 2, 2, math.NaN(), math.NaN(), 4, 0})
+	data.Resample(4)
+	if !feq(data.raw[:2], []float64{5, 2}) || !math.IsNaN(data.raw[2]) || data.raw[3] != 2 {
+		t.Errorf("Unexpected average %v", data.raw)
+	}
+	if !feq(data.low[:2], []float64{1, 2}) || data.low[3] != 0 {
+		t.Errorf("Unexpected low %v", data.low)
+	}
+	if !feq(data.high[:2], []float64{9, 2}) || data.high[3] != 4 {
+		t.Errorf("Unexpected high %v", data.high)
+	}
+
+	_, max, _ := data.MinMaxAvg()
+	if max != 9 {
+		t.Errorf("Max should be calculated from the source data, got %f", max)
+	}
+
+	data.normalize(9)
+	expect := []int{1, 2, Missing, 0}
+	if !eq(data.Low, expect) {
+		t.Errorf("Expected %v got %v", expect, data.Low)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	in := []float64{1, 3, 2, 8, math.NaN(), 4}
+	var td = []struct {
+		method string
+		expect []float64
+	}{
+		{"avg", []float64{2, 5, 4}},
+		{"sum", []float64{4, 10, 4}},
+		{"min", []float64{1, 2, 4}},
+		{"max", []float64{3, 8, 4}},
+		{"first", []float64{1, 2, 4}},
+		{"last", []float64{3, 8, 4}},
+		{"percentile", []float64{2.8, 7.4, 4}},
+		{"m4", []float64{3, 8, 4}},
+	}
+	for _, x := range td {
+		data := NewData(&Options{Downsample: x.method, Percentile: 90}, in)
+		data.Resample(3)
+		for i := range x.expect {
+			if math.Abs(data.raw[i]-x.expect[i]) > 1e-9 {
+				t.Errorf("%s: expected %v got %v", x.method, x.expect, data.raw)
+				break
+			}
+		}
+	}
+
+	for opt, msg := range map[Options]string{
+		{Downsample: "median"}:                 `unknown downsample method "median"`,
+		{Downsample: "percentile"}:             "percentile downsampling requires a percentile between 0 and 100, got 0",
+		{Downsample: "avg", Upsample: "cubic"}: `unknown upsample method "cubic"`,
+	} {
+		if err := opt.Validate(); err == nil || err.Error() != msg {
+			t.Errorf("Expected %q, got %v", msg, err)
+		}
+	}
+
+	ones := make([]float64, 1000)
+	for i := range ones {
+		ones[i] = 1
+	}
+	data := NewData(&Options{Downsample: "sum"}, ones)
+	data.Resample(100)
+	data.normalize(50)
+	for _, v := range data.Values {
+		if v < 0 || v > 50 {
+			t.Fatalf("sum should be normalized within the chart height, got %v (max %f)", data.Values, data.Max)
+		}
+	}
+
+	data = NewData(&Options{Downsample: "m4"}, in)
+	data.Resample(3)
+	if !feq(data.low, []float64{1, 2, 4}) || !feq(data.high, []float64{3, 8, 4}) {
+		t.Errorf("m4 should keep the min/max envelope, got %v %v", data.low, data.high)
+	}
+}
+
+func TestUpsample(t *testing.T) {
+	data := NewData(&Options{Upsample: "linear"}, []float64{0, 10, 20})
+	data.Resample(5)
+	expect := []float64{0, 5, 10, 15, 20}
+	if !feq(data.raw, expect) {
+		t.Errorf("Expected %v got %v", expect, data.raw)
+	}
+
+	data = NewData(&Options{Upsample: "step", Gap: .25}, []float64{1, 2})
+	data.Resample(8)
+	expect = []float64{1, 1, 1, 1, 2, 2, 2, 2}
+	if !feq(data.raw, expect) {
+		t.Errorf("Expected %v got %v", expect, data.raw)
+	}
+
+	data = NewData(&Options{Gap: .25}, []float64{1, 2})
+	data.Resample(8)
+	expect = []float64{0, 1, 1, 1, 0, 2, 2, 2}
+	if !feq(data.raw, expect) {
+		t.Errorf("Expected %v got %v", expect, data.raw)
+	}
+}
+
+func TestRate(t *testing.T) {
+	ts := []time.Time{}
+	for i := 0; i < 7; i++ {
+		ts = append(ts, time.Unix(int64(i*10), 0))
+	}
+	nan := math.NaN()
+	in := []float64{100, 200, 4294967200, 104, nan, 304, 10}
+	var td = []struct {
+		o      *RateOptions
+		expect []float64
+	}{
+		{nil, []float64{nan, 10, 429496700, nan, nan, 10, nan}},
+		{&RateOptions{Bits: 32}, []float64{nan, 10, 429496700, 20, nan, 10, nan}},
+		{&RateOptions{Bits: 32, MaxRate: 1000, ToBits: true}, []float64{nan, 80, nan, 160, nan, 80, nan}},
+		{&RateOptions{Bits: 64}, []float64{nan, 10, 429496700, nan, nan, 10, nan}},
+	}
+	for _, x := range td {
+		res := Rate(ts, in, x.o)
+		for i := range res {
+			if math.IsNaN(res[i]) != math.IsNaN(x.expect[i]) || math.Abs(res[i]-x.expect[i]) > 1e-6 {
+				t.Errorf("%+v: expected %v got %v", x.o, x.expect, res)
+				break
+			}
+		}
+	}
+}
+
+func TestNaN(t *testing.T) {
+	nan := math.NaN()
+	data := NewData(&Options{}, []float64{nan, 2, nan, nan, nan, nan, 4, 6, nan})
+	m, x, a := data.MinMaxAvg()
+	if m != 2 || x != 6 || a != 4 {
+		t.Errorf("Expected 2 6 4 got %f %f %f", m, x, a)
+	}
+
+	data.Resample(5)
+	if math.IsNaN(data.raw[1]) || !math.IsNaN(data.raw[2]) {
+		t.Errorf("Unexpected lttb result %v", data.raw)
+	}
+
+	data.normalize(6)
+	if data.Values[2] != Missing {
+		t.Errorf("NaN should be normalized to Missing, got %v", data.Values)
+	}
+}
+
+func TestStats(t *testing.T) {
+	nan := math.NaN()
+	data := NewData(&Options{}, []float64{2, 4, nan, 4, 4, 5, 5, 7, 9, nan})
+	s := data.Stats()
+	if s.Min != 2 || s.Max != 9 || s.Avg != 5 || s.Sum != 40 || s.Last != 9 || s.Stddev != 2 || s.Count != 8 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	var td = []struct {
+		name   string
+		expect float64
+	}{
+		{"p0", 2},
+		{"p50", 4.5},
+		{"p100", 9},
+		{"min", 2},
+		{"max", 9},
+		{"avg", 5},
+		{"sum", 40},
+		{"last", 9},
+		{"stddev", 2},
+		{"count", 8},
+	}
+	for _, x := range td {
+		v, err := data.Stat(x.name)
+		if err != nil || v != x.expect {
+			t.Errorf("%s: expected %v got %v (%v)", x.name, x.expect, v, err)
+		}
+	}
+
+	for _, name := range []string{"p101", "pNaN", "p-1", "median", "p", ""} {
+		if ValidStat(name) {
+			t.Errorf("%q should not be a valid statistic", name)
+		}
+		if _, err := data.Stat(name); err == nil {
+			t.Errorf("%q should return an error", name)
+		}
+	}
+}
+
+func TestStatsSum(t *testing.T) {
+	data := NewData(&Options{Downsample: "sum"}, []float64{1, 2, 3, 4})
+	data.Resample(2)
+	data.normalize(10)
+	if data.Max != 7 {
+		t.Errorf("Expected the max of the sums, got %v", data.Max)
+	}
+	if s := data.Stats(); s.Max != 4 || s.Avg != 2.5 {
+		t.Errorf("Expected stats of the source values, got %+v", s)
+	}
+}
+
+func TestPoints(t *testing.T) {
+	start := time.Unix(1000, 0)
+	p := func(s int, v float64) Point { return Point{start.Add(time.Duration(s) * time.Second), v} }
+	res := Points([]Point{p(35, 4), p(0, 1), p(5, 3), p(40, 2), p(-1, 9), p(41, 9), p(20, math.NaN())}, start, start.Add(40*time.Second), 4)
+	expect := []float64{2, math.NaN(), math.NaN(), 3}
+	for i := range expect {
+		if res[i] != expect[i] && !(math.IsNaN(res[i]) && math.IsNaN(expect[i])) {
+			t.Errorf("Expected %v, got %v", expect, res)
+			break
+		}
+	}
+}
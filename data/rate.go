@@ -0,0 +1,88 @@
+package data
+
+import (
+	"math"
+	"time"
+)
+
+// RateOptions configures the conversion of counter values to rates.
+type RateOptions struct {
+	// Bits is the width of the counter, 32 or 64. A counter which decreases
+	// is considered to have wrapped around when the wrapped difference is
+	// less than half the counter range, otherwise it is considered to be
+	// reset. If Bits is 0 every decrease is considered a reset.
+	Bits int
+
+	// MaxRate is the largest plausible rate per second. Larger rates are
+	// considered resets. By default all rates are plausible.
+	MaxRate float64
+
+	// ToBits multiplies all rates by 8, e.g. to convert bytes to bits.
+	ToBits bool
+}
+
+// Counter converts successive readings of a monotonic counter to rates
+// per second. The zero value is ready to use.
+type Counter struct {
+	RateOptions
+	last  float64
+	t     time.Time
+	valid bool
+}
+
+// NewCounter returns a new Counter using the options o.
+func NewCounter(o *RateOptions) *Counter {
+	c := &Counter{}
+	if o != nil {
+		c.RateOptions = *o
+	}
+	return c
+}
+
+// Update adds a new counter reading taken at time t and returns the rate per
+// second since the previous reading. The first reading, readings after a
+// counter reset and NaN readings return NaN.
+func (c *Counter) Update(t time.Time, v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
+	}
+	last, lt, valid := c.last, c.t, c.valid
+	c.last, c.t, c.valid = v, t, true
+
+	tdelta := t.Sub(lt).Seconds()
+	if !valid || tdelta <= 0 {
+		return math.NaN()
+	}
+
+	delta := v - last
+	if delta < 0 {
+		if c.Bits <= 0 {
+			return math.NaN()
+		}
+		delta += math.Pow(2, float64(c.Bits))
+		if delta < 0 || delta > math.Pow(2, float64(c.Bits-1)) {
+			return math.NaN()
+		}
+	}
+
+	rate := delta / tdelta
+	if c.MaxRate > 0 && rate > c.MaxRate {
+		return math.NaN()
+	}
+	if c.ToBits {
+		rate *= 8
+	}
+	return rate
+}
+
+// Rate converts counter values sampled at times t to rates per second.
+// The first value, NaN values and counter resets result in NaN, which
+// is plotted as a gap instead of a spike.
+func Rate(t []time.Time, values []float64, o *RateOptions) []float64 {
+	c := NewCounter(o)
+	res := make([]float64, len(values))
+	for i, v := range values {
+		res[i] = c.Update(t[i], v)
+	}
+	return res
+}
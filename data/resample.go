@@ -2,29 +2,154 @@ package data
 
 import "math"
 
-// Resample resamples the raw data. It either streches the data to fit the witdth
-// or it uses the Largest Triangle Three Bucket algorithm to fit the data to the new width.
+// Resample resamples the raw data to width. When upsampling it stretches the data
+// using the Upsample method of the dataset. When downsampling it uses the
+// Downsample method, by default the Largest Triangle Three Bucket algorithm.
 func (d *Data) Resample(width int) {
 	if len(d.raw) < width {
-		d.raw = d.stretch(width)
+		d.raw = d.upsample(width)
 	} else if len(d.raw) > width {
-		d.raw = d.lttb(width)
+		if d.env || d.down == "m4" {
+			d.low = d.buckets(width, minOf)
+			d.high = d.buckets(width, maxOf)
+		}
+		d.raw = d.downsample(width)
+	}
+}
+
+// upsample stretches the raw array into a new width using the upsample method.
+func (d *Data) upsample(width int) []float64 {
+	switch d.up {
+	case "linear":
+		return d.linear(width)
+	case "step":
+		return d.stretch(width, 0)
+	}
+	return d.stretch(width, d.gap)
+}
+
+// downsample shrinks the raw array into a new width using the downsample method.
+func (d *Data) downsample(width int) []float64 {
+	switch d.down {
+	case "avg":
+		return d.buckets(width, avgOf)
+	case "sum":
+		return d.buckets(width, sumOf)
+	case "min":
+		return d.buckets(width, minOf)
+	case "max":
+		return d.buckets(width, maxOf)
+	case "first":
+		return d.buckets(width, firstOf)
+	case "last", "m4":
+		return d.buckets(width, lastOf)
+	case "percentile":
+		return d.buckets(width, func(v []float64) float64 {
+			return percentile(v, d.pct)
+		})
+	case "":
+		if d.env {
+			return d.buckets(width, avgOf)
+		}
+	}
+	return d.lttb(width)
+}
+
+// buckets downsamples the raw array to width by dividing the values in equally
+// sized buckets, one for each pixel, and calling f for each bucket. NaN values
+// are not passed to f, buckets containing only NaN values result in NaN.
+func (d *Data) buckets(width int, f func([]float64) float64) []float64 {
+	res := make([]float64, width)
+	L := len(d.raw)
+	vals := make([]float64, 0, L/width+1)
+	for i := 0; i < width; i++ {
+		vals = vals[:0]
+		for _, v := range d.raw[i*L/width : (i+1)*L/width] {
+			if !math.IsNaN(v) {
+				vals = append(vals, v)
+			}
+		}
+		if len(vals) == 0 {
+			res[i] = math.NaN()
+			continue
+		}
+		res[i] = f(vals)
+	}
+	return res
+}
+
+func avgOf(v []float64) float64 {
+	return sumOf(v) / float64(len(v))
+}
+
+func sumOf(v []float64) float64 {
+	s := 0.
+	for _, x := range v {
+		s += x
 	}
+	return s
+}
+
+func minOf(v []float64) float64 {
+	m := v[0]
+	for _, x := range v[1:] {
+		m = math.Min(m, x)
+	}
+	return m
+}
+
+func maxOf(v []float64) float64 {
+	m := v[0]
+	for _, x := range v[1:] {
+		m = math.Max(m, x)
+	}
+	return m
+}
+
+func firstOf(v []float64) float64 {
+	return v[0]
+}
+
+func lastOf(v []float64) float64 {
+	return v[len(v)-1]
+}
+
+// linear stretches the raw array into a new width using linear interpolation.
+func (d *Data) linear(width int) []float64 {
+	newdata := make([]float64, width)
+	L := len(d.raw)
+	if L == 1 || width == 1 {
+		for i := range newdata {
+			newdata[i] = d.raw[0]
+		}
+		return newdata
+	}
+	for i := 0; i < width; i++ {
+		idx := float64(L-1) / float64(width-1) * float64(i)
+		n := int(idx)
+		if n >= L-1 {
+			newdata[i] = d.raw[L-1]
+			continue
+		}
+		f := idx - float64(n)
+		newdata[i] = d.raw[n]*(1-f) + d.raw[n+1]*f
+	}
+	return newdata
 }
 
 // stretch stretches the raw array into a new width.
 // It just stretches using the same values without any interpolation.
-// If Data has a gap specified, a gap amount % of whitespace
+// If a gap is specified, a gap amount % of whitespace
 // will be added between the bars using 0 values. The gap % is
 // present on both the left and right side of a bar.
-func (d *Data) stretch(width int) []float64 {
+func (d *Data) stretch(width int, gap float64) []float64 {
 	newdata := make([]float64, width)
 	max := len(d.raw)
 	for i := 0; i < width; i++ {
 		idx := float64(max) / float64(width) * float64(i)
 		v := d.raw[int(idx)]
 		f := idx - float64(int(idx))
-		if f < d.gap || f > 1-d.gap {
+		if f < gap || f > 1-gap {
 			v = 0
 		}
 		newdata[i] = v
@@ -34,6 +159,7 @@ func (d *Data) stretch(width int) []float64 {
 
 // lttb implements Largest Triangle Three Bucket downsampling algorithm.
 // Converted to Go from several implementations found online.
+// NaN values are skipped, buckets containing only NaN values result in NaN.
 func (d *Data) lttb(width int) []float64 {
 	L := len(d.raw)
 	res := make([]float64, width)
@@ -55,12 +181,17 @@ func (d *Data) lttb(width int) []float64 {
 		if rangeEnd > L {
 			rangeEnd = L
 		}
-		rangeLen := rangeEnd - rangeStart
+		rangeLen := 0
 
 		for ; rangeStart < rangeEnd; rangeStart++ {
-			avgy += d.raw[rangeStart]
+			if !math.IsNaN(d.raw[rangeStart]) {
+				avgy += d.raw[rangeStart]
+				rangeLen++
+			}
+		}
+		if rangeLen > 0 {
+			avgy /= float64(rangeLen)
 		}
-		avgy /= float64(rangeLen)
 
 		// Get range for bucket
 		rangeOff := int(math.Floor(float64(i)*every) + 1)
@@ -69,10 +200,16 @@ func (d *Data) lttb(width int) []float64 {
 		pax := pos
 		pay := d.raw[pos]
 		maxArea := -1.
-		maxpx := 0.
+		maxpx := math.NaN() // bucket without values
 		for ; rangeOff < rangeTo; rangeOff++ {
+			if math.IsNaN(d.raw[rangeOff]) {
+				continue
+			}
 			// calc triangle over 3 bucket
 			area := math.Abs((float64(pax)-avgy)*(d.raw[rangeOff]-pay)-(float64(pax-rangeOff))*(avgy*pay)) * .5
+			if math.IsNaN(area) {
+				area = 0
+			}
 			if area > maxArea {
 				maxArea = area
 				maxpx = d.raw[rangeOff]
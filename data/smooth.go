@@ -0,0 +1,144 @@
+package data
+
+import (
+	"math"
+	"sort"
+)
+
+// Smoother identifies a smoothing algorithm.
+type Smoother string
+
+const (
+	// SMA is a centered simple moving average.
+	SMA Smoother = "sma"
+
+	// WMA is a centered, linearly (triangular) weighted moving average.
+	WMA Smoother = "wma"
+
+	// EMA is an exponential moving average using alpha 2/(window+1).
+	EMA Smoother = "ema"
+
+	// Median is a centered moving median filter.
+	Median Smoother = "median"
+)
+
+// Smoothing configures a smoothed overlay for a dataset. The same
+// algorithms are used by the moving average button of the svg image.
+type Smoothing struct {
+	// Method is the smoothing algorithm, leave empty to disable smoothing.
+	Method Smoother `json:"method"`
+
+	// Window is the window size in samples (pixels). If Window is larger
+	// than the dataset all values are used.
+	Window int `json:"window"`
+
+	// Min is the minimum value to take into account. Smaller values, like
+	// the gaps between bar charts, are skipped. NaN values are always skipped.
+	Min float64 `json:"min"`
+}
+
+// Smooth returns a smoothed copy of in using the algorithm specified in s.
+// Positions without any usable values in their window are set to NaN.
+func Smooth(in []float64, s Smoothing) []float64 {
+	res := make([]float64, len(in))
+	if len(in) == 0 {
+		return res
+	}
+	size := s.Window
+	if size < 1 {
+		size = 1
+	}
+	valid := func(v float64) bool {
+		return !math.IsNaN(v) && v >= s.Min
+	}
+
+	switch s.Method {
+	case EMA:
+		alpha := 2. / float64(size+1)
+		e := math.NaN()
+		for i, v := range in {
+			if valid(v) {
+				if math.IsNaN(e) {
+					e = v
+				} else {
+					e = alpha*v + (1-alpha)*e
+				}
+			}
+			res[i] = e
+		}
+		return res
+
+	case Median:
+		if size > len(in) {
+			size = len(in)
+		}
+		half := size / 2
+		win := make([]float64, 0, size+1)
+		for i := range in {
+			win = win[:0]
+			for j := i - half; j <= i+half; j++ {
+				if j >= 0 && j < len(in) && valid(in[j]) {
+					win = append(win, in[j])
+				}
+			}
+			res[i] = median(win)
+		}
+		return res
+
+	case SMA, WMA:
+		if size >= len(in) {
+			// average over all values
+			sum, n := 0., 0
+			for _, v := range in {
+				if valid(v) {
+					sum += v
+					n++
+				}
+			}
+			for i := range res {
+				res[i] = sum / float64(n)
+			}
+			return res
+		}
+		half := size / 2
+		for i := range in {
+			sum, totw := 0., 0.
+			for j := -half; j <= half; j++ {
+				if i+j < 0 || i+j >= len(in) || !valid(in[i+j]) {
+					continue
+				}
+				w := 1.
+				if s.Method == WMA {
+					w = float64(half + 1 - abs(j))
+				}
+				sum += in[i+j] * w
+				totw += w
+			}
+			res[i] = sum / totw
+		}
+		return res
+	}
+
+	copy(res, in)
+	return res
+}
+
+// median returns the median value of in. It sorts in.
+func median(in []float64) float64 {
+	if len(in) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(in)
+	n := len(in)
+	if n%2 == 1 {
+		return in[n/2]
+	}
+	return (in[n/2-1] + in[n/2]) / 2
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"math"
+	"time"
+)
+
+// Point is a value at a point in time.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// DataSource is a source of timestamped values, like a time series
+// database, which is queried when the chart is rendered. This avoids
+// fetching more values than the chart has pixels.
+type DataSource interface {
+	// Query returns the points from start to end at a resolution of
+	// about one point per step. Sources may return more or fewer points
+	// at any resolution, the points are resampled to the chart width.
+	Query(start, end time.Time, step time.Duration) ([]Point, error)
+}
+
+// SourceFunc is a function which implements DataSource.
+type SourceFunc func(start, end time.Time, step time.Duration) ([]Point, error)
+
+// Query calls f.
+func (f SourceFunc) Query(start, end time.Time, step time.Duration) ([]Point, error) {
+	return f(start, end, step)
+}
+
+// Points returns n evenly spaced values from start to end of the points,
+// which may be in any order. Points in the same interval are averaged,
+// intervals without points are missing (NaN) and points outside of the
+// range are ignored.
+func Points(points []Point, start, end time.Time, n int) []float64 {
+	sum := make([]float64, n)
+	cnt := make([]int, n)
+	span := end.Sub(start)
+	for _, p := range points {
+		if p.Time.Before(start) || p.Time.After(end) || math.IsNaN(p.Value) {
+			continue
+		}
+		i := int(float64(p.Time.Sub(start)) / float64(span) * float64(n))
+		if i >= n {
+			i = n - 1
+		}
+		sum[i] += p.Value
+		cnt[i]++
+	}
+	for i := range sum {
+		if cnt[i] == 0 {
+			sum[i] = math.NaN()
+			continue
+		}
+		sum[i] /= float64(cnt[i])
+	}
+	return sum
+}
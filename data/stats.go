@@ -0,0 +1,155 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// percentile returns the p-th percentile (0-100) of v, using linear
+// interpolation between the closest ranks. It does not modify v.
+func percentile(v []float64, p float64) float64 {
+	if len(v) == 0 {
+		return math.NaN()
+	}
+	s := make([]float64, len(v))
+	copy(s, v)
+	sort.Float64s(s)
+
+	rank := p / 100 * float64(len(s)-1)
+	if rank <= 0 {
+		return s[0]
+	}
+	if rank >= float64(len(s)-1) {
+		return s[len(s)-1]
+	}
+	n := int(rank)
+	f := rank - float64(n)
+	return s[n]*(1-f) + s[n+1]*f
+}
+
+// Stats contains statistics of the source values of a dataset.
+type Stats struct {
+	Min    float64 // smallest non zero value
+	Max    float64
+	Avg    float64
+	Sum    float64
+	Last   float64 // last value which is not NaN
+	Stddev float64 // population standard deviation
+	Count  int     // number of values which are not NaN
+}
+
+// Stats returns statistics of the source data, before it was resampled.
+// NaN values are ignored.
+func (d *Data) Stats() Stats {
+	s := Stats{}
+	s.Min, s.Max, s.Avg = minMaxAvg(d.src)
+	s.Sum, s.Last, s.Count = sumLastCount(d.src)
+	s.Stddev = stddev(d.src, s.Avg)
+	return s
+}
+
+// sumLastCount returns the sum, the last value and the number of values of
+// v, ignoring NaN values.
+func sumLastCount(v []float64) (float64, float64, int) {
+	sum, last, n := 0., 0., 0
+	for _, x := range v {
+		if math.IsNaN(x) {
+			continue
+		}
+		sum += x
+		last = x
+		n++
+	}
+	return sum, last, n
+}
+
+// stddev returns the population standard deviation of v with average avg,
+// ignoring NaN values.
+func stddev(v []float64, avg float64) float64 {
+	sq, n := 0., 0
+	for _, x := range v {
+		if math.IsNaN(x) {
+			continue
+		}
+		sq += (x - avg) * (x - avg)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return math.Sqrt(sq / float64(n))
+}
+
+// Percentile returns the p-th percentile (0-100) of the source data,
+// before it was resampled. NaN values are ignored.
+func (d *Data) Percentile(p float64) float64 {
+	v := make([]float64, 0, len(d.src))
+	for _, x := range d.src {
+		if !math.IsNaN(x) {
+			v = append(v, x)
+		}
+	}
+	return percentile(v, p)
+}
+
+// Stat returns a single statistic by name. Valid names are "min", "max",
+// "avg", "sum", "last", "stddev", "count" or a percentile like "p95".
+func (d *Data) Stat(name string) (float64, error) {
+	if p, ok := parsePercentile(name); ok {
+		return d.Percentile(p), nil
+	}
+	if !validStat(name) {
+		return 0, fmt.Errorf("unknown statistic %q", name)
+	}
+	switch name {
+	case "min":
+		min, _, _ := minMaxAvg(d.src)
+		return min, nil
+	case "max":
+		_, max, _ := minMaxAvg(d.src)
+		return max, nil
+	case "avg":
+		_, _, avg := minMaxAvg(d.src)
+		return avg, nil
+	case "stddev":
+		_, _, avg := minMaxAvg(d.src)
+		return stddev(d.src, avg), nil
+	}
+	sum, last, n := sumLastCount(d.src)
+	switch name {
+	case "sum":
+		return sum, nil
+	case "last":
+		return last, nil
+	}
+	return float64(n), nil
+}
+
+// ValidStat reports whether name is a valid statistic name for Stat.
+func ValidStat(name string) bool {
+	_, ok := parsePercentile(name)
+	return ok || validStat(name)
+}
+
+func validStat(name string) bool {
+	switch name {
+	case "min", "max", "avg", "sum", "last", "stddev", "count":
+		return true
+	}
+	return false
+}
+
+// parsePercentile parses a percentile statistic name like "p95" or "p99.9".
+func parsePercentile(name string) (float64, bool) {
+	if !strings.HasPrefix(name, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(name[1:], 64)
+	if err != nil || math.IsNaN(p) || p < 0 || p > 100 {
+		return 0, false
+	}
+	return p, true
+}
@@ -11,9 +11,13 @@ import (
 
 	"github.com/tomarus/chart"
 	"github.com/tomarus/chart/axis"
+	"github.com/tomarus/chart/canvas"
+	"github.com/tomarus/chart/config"
 	"github.com/tomarus/chart/data"
 	"github.com/tomarus/chart/image"
 	"github.com/tomarus/chart/png"
+	"github.com/tomarus/chart/render"
+	"github.com/tomarus/chart/spec"
 	"github.com/tomarus/chart/svg"
 )
 
@@ -35,8 +39,19 @@ func main() {
 		drawChartSmall(w, r, svg.New())
 	})
 	http.HandleFunc("/chartsmall.png", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "image/png")
-		drawChartSmall(w, r, png.New())
+		img := png.New().Encoding(r.FormValue("format"))
+		w.Header().Set("Content-Type", img.ContentType())
+		drawChartSmall(w, r, img)
+	})
+
+	http.HandleFunc("/chart.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		drawChart(w, r, canvas.New(), iFormValue(r, "width"), fFormValue(r, "h1"), fFormValue(r, "h2"), fFormValue(r, "add1"), fFormValue(r, "add2"))
+	})
+
+	http.HandleFunc("/chart.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		drawChart(w, r, spec.New(), iFormValue(r, "width"), fFormValue(r, "h1"), fFormValue(r, "h2"), fFormValue(r, "add1"), fFormValue(r, "add2"))
 	})
 
 	http.HandleFunc("/chartthemed.svg", func(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +63,11 @@ func main() {
 		drawChartThemed(w, r, png.New(), r.FormValue("theme"), r.FormValue("scheme"))
 	})
 
+	http.Handle("/render/", render.New(config.Values{
+		"sin":  mksin(1440, 256, 1, 1),
+		"sin2": mksin(1440, 128, 3, 1),
+	}))
+
 	http.HandleFunc("/", html)
 
 	log.Printf("Listening on %s", *listen)
@@ -95,17 +115,22 @@ const htmlbasic = `
 <div>
 	<h2>Basic Charts</h2>
 	<object data="/chart.svg?h1=256&h2=128&add1=1&add2=1"></object>
-	<img src="/chart.png?h1=256&h2=128&add1=1&add2=1"></img>
+	<img src="/chart.png?h1=256&h2=128&add1=1&add2=1" srcset="/chart.png?h1=256&h2=128&add1=1&add2=1&scale=2 2x, /chart.png?h1=256&h2=128&add1=1&add2=1&scale=3 3x"></img>
 </div>
 <div>
 	<h2>Small Value Charts</h2>
 	<object data="/chart.svg?h1=1&h2=0.5&add1=3&add2=3"></object>
-	<img src="/chart.png?h1=1&h2=0.5&add1=3&add2=3"></img>
+	<img src="/chart.png?h1=1&h2=0.5&add1=3&add2=3" srcset="/chart.png?h1=1&h2=0.5&add1=3&add2=3&scale=2 2x, /chart.png?h1=1&h2=0.5&add1=3&add2=3&scale=3 3x"></img>
 </div>
 <div>
 	<h2>Few Values Charts</h2>
 	<object data="/chartsmall.svg"></object>
-	<img src="/chartsmall.png"></img>
+	<img src="/chartsmall.png" srcset="/chartsmall.png?scale=2 2x, /chartsmall.png?scale=3 3x"></img>
+</div>
+<div>
+	<h2>Render Endpoint</h2>
+	<object data="/render/chart.svg?title=Render&size=small&height=120&target=sin&target=sin2&legend.stats=max,avg"></object>
+	<img src="/render/chart.png?title=Render&size=small&height=120&target=sin&target=sin2&legend.stats=max,avg"></img>
 </div>
 `
 
@@ -114,16 +139,16 @@ const htmlcolors = `
 	<h2>Themed Charts</h2>
 	<h3>Scheme "white"</h3>
 	<object data="/chartthemed.svg?scheme=white"></object>
-	<img src="/chartthemed.png?scheme=white"></img>
+	<img src="/chartthemed.png?scheme=white" srcset="/chartthemed.png?scheme=white&scale=2 2x, /chartthemed.png?scheme=white&scale=3 3x"></img>
 	<h3>Scheme "black"</h3>
 	<object data="/chartthemed.svg?scheme=black"></object>
-	<img src="/chartthemed.png?scheme=black"></img>
+	<img src="/chartthemed.png?scheme=black" srcset="/chartthemed.png?scheme=black&scale=2 2x, /chartthemed.png?scheme=black&scale=3 3x"></img>
 	<h3>Scheme "pink"</h3>
 	<object data="/chartthemed.svg?scheme=pink"></object>
-	<img src="/chartthemed.png?scheme=pink"></img>
+	<img src="/chartthemed.png?scheme=pink" srcset="/chartthemed.png?scheme=pink&scale=2 2x, /chartthemed.png?scheme=pink&scale=3 3x"></img>
 	<h3>Scheme "solarized"</h3>
 	<object data="/chartthemed.svg?scheme=solarized"></object>
-	<img src="/chartthemed.png?scheme=solarized"></img>
+	<img src="/chartthemed.png?scheme=solarized" srcset="/chartthemed.png?scheme=solarized&scale=2 2x, /chartthemed.png?scheme=solarized&scale=3 3x"></img>
 </div>
 `
 
@@ -131,20 +156,20 @@ const htmlrandom = `
 <div>
 	<h3>Scheme "random" theme "dark"</h3>
 	<object data="/chartthemed.svg?theme=dark&scheme=random"></object>
-	<img src="/chartthemed.png?theme=dark&scheme=random"></img>
+	<img src="/chartthemed.png?theme=dark&scheme=random" srcset="/chartthemed.png?theme=dark&scheme=random&scale=2 2x, /chartthemed.png?theme=dark&scheme=random&scale=3 3x"></img>
 	<h3>Scheme "random" theme "light"</h3>
 	<object data="/chartthemed.svg?theme=light&scheme=random"></object>
-	<img src="/chartthemed.png?theme=light&scheme=random"></img>
+	<img src="/chartthemed.png?theme=light&scheme=random" srcset="/chartthemed.png?theme=light&scheme=random&scale=2 2x, /chartthemed.png?theme=light&scheme=random&scale=3 3x"></img>
 
 	<h3>Scheme "hsl:0,0.66,0.5" theme "light"</h3>
 	<object data="/chartthemed.svg?theme=light&scheme=hsl:0,0.66,0.5"></object>
-	<img src="/chartthemed.png?theme=light&scheme=hsl:0,0.66,0.5"></img>
+	<img src="/chartthemed.png?theme=light&scheme=hsl:0,0.66,0.5" srcset="/chartthemed.png?theme=light&scheme=hsl:0,0.66,0.5&scale=2 2x, /chartthemed.png?theme=light&scheme=hsl:0,0.66,0.5&scale=3 3x"></img>
 	<h3>Scheme "hsl:320,0.25,0.5" theme "light"</h3>
 	<object data="/chartthemed.svg?theme=light&scheme=hsl:320,0.25,0.5"></object>
-	<img src="/chartthemed.png?theme=light&scheme=hsl:320,0.25,0.5"></img>
+	<img src="/chartthemed.png?theme=light&scheme=hsl:320,0.25,0.5" srcset="/chartthemed.png?theme=light&scheme=hsl:320,0.25,0.5&scale=2 2x, /chartthemed.png?theme=light&scheme=hsl:320,0.25,0.5&scale=3 3x"></img>
 	<h3>Scheme "hsl:120,0.5,0.4" theme "dark"</h3>
 	<object data="/chartthemed.svg?theme=dark&scheme=hsl:120,0.5,0.4"></object>
-	<img src="/chartthemed.png?theme=dark&scheme=hsl:120,0.5,0.4"></img>
+	<img src="/chartthemed.png?theme=dark&scheme=hsl:120,0.5,0.4" srcset="/chartthemed.png?theme=dark&scheme=hsl:120,0.5,0.4&scale=2 2x, /chartthemed.png?theme=dark&scheme=hsl:120,0.5,0.4&scale=3 3x"></img>
 </div>
 `
 
@@ -178,6 +203,7 @@ func drawChart(w http.ResponseWriter, r *http.Request, img image.Image, width in
 		Start:  time.Now().AddDate(0, 0, -2).Unix(),
 		End:    time.Now().Unix(),
 		W:      w,
+		Scale:  fFormValue(r, "scale"),
 		Axes: []*axis.Axis{
 			axis.NewTime(axis.Bottom, "Mon 15:04").Duration(8 * time.Hour).Grid(4),
 			axis.NewSI(axis.Left, 1000).Ticks(4).Grid(2),
@@ -208,6 +234,7 @@ func drawChartSmall(w http.ResponseWriter, r *http.Request, img image.Image) {
 		Start:  time.Now().AddDate(0, 0, -30).Unix(),
 		End:    time.Now().Unix(),
 		W:      w,
+		Scale:  fFormValue(r, "scale"),
 		Axes: []*axis.Axis{
 			axis.NewTime(axis.Bottom, "02").Duration(1 * 86400 * time.Second).Grid(1).Center(),
 			axis.NewSI(axis.Left, 1000).Ticks(10).Grid(1),
@@ -230,6 +257,7 @@ func drawChartThemed(w http.ResponseWriter, r *http.Request, img image.Image, th
 		Start:  time.Now().AddDate(0, 0, -2).Unix(),
 		End:    time.Now().Unix(),
 		W:      w,
+		Scale:  fFormValue(r, "scale"),
 		Axes: []*axis.Axis{
 			axis.NewTime(axis.Bottom, "Mon 15:04").Duration(8 * time.Hour).Grid(4),
 			axis.NewSI(axis.Left, 1000).Ticks(4).Grid(2),
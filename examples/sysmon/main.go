@@ -2,6 +2,7 @@
 // the last 15 minutes of data, sampled each second, in memory.
 // It monitors and plots usage data for cpu, free mem,
 // network, load avg, procs and disk io.
+// Open /live for svg charts which are updated using Server-Sent Events.
 // It's an example using the tomarus/chart and c9s/goprocinfo packages.
 package main
 
@@ -11,13 +12,18 @@ import (
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tomarus/chart"
 	"github.com/tomarus/chart/axis"
 	"github.com/tomarus/chart/data"
 	"github.com/tomarus/chart/examples/sysmon/mods"
+	"github.com/tomarus/chart/image"
 	"github.com/tomarus/chart/png"
+	"github.com/tomarus/chart/stream"
+	"github.com/tomarus/chart/svg"
 )
 
 const delay = 1 * time.Second
@@ -31,26 +37,55 @@ var inputs = []mods.Collector{
 	&mods.DiskStat{},
 }
 
+var names = []string{"cpu", "mem", "net", "load", "proc", "io"}
+
+var streams = make([]*stream.Ring, len(inputs))
+
+func init() {
+	for i := range streams {
+		streams[i] = stream.NewRing(60)
+	}
+}
+
+// push sends the last value of each dataset to the event stream of input i.
+func push(i int, t time.Time) {
+	v := map[string]float64{}
+	for _, d := range inputs[i].Data() {
+		if len(d.Values) > 0 {
+			v[d.Title] = d.Values[len(d.Values)-1]
+		}
+	}
+	streams[i].Push(t, v)
+}
+
 func collector() {
 	t := time.NewTicker(delay)
-	for range t.C {
+	for now := range t.C {
 		for i := range inputs {
-			go func(i int) {
+			go func(i int, now time.Time) {
 				err := inputs[i].Update()
 				if err != nil {
 					log.Printf("Update %v: %v", inputs[i], err)
+					return
 				}
-			}(i)
+				push(i, now)
+			}(i, now)
 		}
 	}
 }
 
-func plot(input mods.Collector, title string, w http.ResponseWriter, r *http.Request) {
+// scale returns the device pixel ratio requested using the scale parameter.
+func scale(r *http.Request) float64 {
+	s, _ := strconv.ParseFloat(r.FormValue("scale"), 64)
+	return s
+}
+
+func plot(input mods.Collector, title string, img image.Image, w http.ResponseWriter, r *http.Request) {
 	L := input.Len()
 	dur := (time.Duration(L) * time.Second) / 5
 	opts := &chart.Options{
 		Title:  title,
-		Image:  png.New(),
+		Image:  img,
 		Width:  900,
 		Height: 300,
 		Scheme: fmt.Sprintf("hsl:%d,0.5,0.5", hashN(title, 120)),
@@ -58,6 +93,7 @@ func plot(input mods.Collector, title string, w http.ResponseWriter, r *http.Req
 		Start:  time.Now().Add(-time.Duration(L) * time.Second).Unix(),
 		End:    time.Now().Unix(),
 		W:      w,
+		Scale:  scale(r),
 		Axes: []*axis.Axis{
 			axis.NewTime(axis.Bottom, "15:04:05").Duration(dur).Grid(3),
 			axis.NewSI(axis.Left, 1000).Ticks(4).Grid(2),
@@ -72,7 +108,7 @@ func plot(input mods.Collector, title string, w http.ResponseWriter, r *http.Req
 	}
 
 	for _, v := range input.Data() {
-		err := ch.AddData(&data.Options{Title: v.Title}, v.Values)
+		err := ch.AddData(&data.Options{Title: v.Title, Unit: v.Unit}, v.Values)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Printf("Data Error: %v", err)
@@ -80,8 +116,11 @@ func plot(input mods.Collector, title string, w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	w.Header().Set("Content-Type", "image/svg+xml")
-	w.Header().Set("Content-Type", "image/png")
+	if _, ok := img.(*svg.SVG); ok {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
 	err = ch.Render()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -102,7 +141,10 @@ var html = `
 window.onload = function() {
     function updateImage(id) {
         var img = document.getElementById(id);
-        img.src = img.src.split("?")[0] + "?" + new Date().getTime();
+        var t = new Date().getTime();
+        var src = img.src.split("?")[0];
+        img.src = src + "?t=" + t;
+        img.srcset = src + "?t=" + t + "&scale=2 2x, " + src + "?t=" + t + "&scale=3 3x";
     }
     var charts = ["mem", "cpu", "net", "load", "proc", "io"]
     const intvl = ` + fmt.Sprintf("%d", delay/time.Millisecond) + `
@@ -117,14 +159,29 @@ window.onload = function() {
 </script>
 </head>
 <body>
-<img align="top" id="load" src="/load.png"></img>
-<img align="top" id="cpu" src="/cpu.png"></img>
+<img align="top" id="load" src="/load.png" srcset="/load.png?scale=2 2x, /load.png?scale=3 3x"></img>
+<img align="top" id="cpu" src="/cpu.png" srcset="/cpu.png?scale=2 2x, /cpu.png?scale=3 3x"></img>
+<br/><p/><br/><p/>
+<img align="top" id="mem" src="/mem.png" srcset="/mem.png?scale=2 2x, /mem.png?scale=3 3x"></img>
+<img align="top" id="net" src="/net.png" srcset="/net.png?scale=2 2x, /net.png?scale=3 3x"></img>
+<br/><p/><br/><p/>
+<img align="top" id="proc" src="/proc.png" srcset="/proc.png?scale=2 2x, /proc.png?scale=3 3x"></img>
+<img align="top" id="io" src="/io.png" srcset="/io.png?scale=2 2x, /io.png?scale=3 3x"></img>
+</body>
+</html>
+`
+
+var htmllive = `
+<html>
+<body>
+<object id="load" data="/load.svg"></object>
+<object id="cpu" data="/cpu.svg"></object>
 <br/><p/><br/><p/>
-<img align="top" id="mem" src="/mem.png"></img>
-<img align="top" id="net" src="/net.png"></img>
+<object id="mem" data="/mem.svg"></object>
+<object id="net" data="/net.svg"></object>
 <br/><p/><br/><p/>
-<img align="top" id="proc" src="/proc.png"></img>
-<img align="top" id="io" src="/io.png"></img>
+<object id="proc" data="/proc.svg"></object>
+<object id="io" data="/io.svg"></object>
 </body>
 </html>
 `
@@ -132,25 +189,22 @@ window.onload = function() {
 func main() {
 	go collector()
 
-	http.HandleFunc("/cpu.png", func(w http.ResponseWriter, r *http.Request) {
-		plot(inputs[0], "CPU", w, r)
-	})
-	http.HandleFunc("/mem.png", func(w http.ResponseWriter, r *http.Request) {
-		plot(inputs[1], "MEM", w, r)
-	})
-	http.HandleFunc("/net.png", func(w http.ResponseWriter, r *http.Request) {
-		plot(inputs[2], "NET", w, r)
-	})
-	http.HandleFunc("/load.png", func(w http.ResponseWriter, r *http.Request) {
-		plot(inputs[3], "LOAD", w, r)
-	})
-	http.HandleFunc("/proc.png", func(w http.ResponseWriter, r *http.Request) {
-		plot(inputs[4], "PROC", w, r)
-	})
-	http.HandleFunc("/io.png", func(w http.ResponseWriter, r *http.Request) {
-		plot(inputs[5], "IO", w, r)
-	})
+	for i, name := range names {
+		i := i
+		title := strings.ToUpper(name)
+		events := "/" + name + ".events"
+		http.HandleFunc("/"+name+".png", func(w http.ResponseWriter, r *http.Request) {
+			plot(inputs[i], title, png.New(), w, r)
+		})
+		http.HandleFunc("/"+name+".svg", func(w http.ResponseWriter, r *http.Request) {
+			plot(inputs[i], title, svg.New().Stream(events), w, r)
+		})
+		http.Handle(events, streams[i])
+	}
 
+	http.HandleFunc("/live", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, htmllive)
+	})
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, html)
 	})
@@ -1,15 +1,16 @@
 package mods
 
 import (
+	"time"
+
 	"github.com/c9s/goprocinfo/linux"
+	"github.com/tomarus/chart/data"
 )
 
 // DiskStat defines global cpu usage. Idle, system and user cpu time are supported.
 type DiskStat struct {
-	rio, wio store
-	lastrio  int64
-	lastwio  int64
-	first    bool
+	rio, wio   store
+	rioc, wioc data.Counter
 }
 
 // Len returns the amount of datapoints of the data available.
@@ -20,8 +21,8 @@ func (c *DiskStat) Len() int {
 // Data returns a slice of all Datasets available.
 func (c *DiskStat) Data() []Dataset {
 	return []Dataset{
-		{"Read Bytes", c.rio.values},
-		{"Write Bytes", c.wio.values},
+		{"Read Bytes", c.rio.values, "B/s"},
+		{"Write Bytes", c.wio.values, "B/s"},
 	}
 }
 
@@ -31,18 +32,14 @@ func (c *DiskStat) Update() error {
 	if err != nil {
 		return err
 	}
+	now := time.Now()
+
 	rio, wio := int64(0), int64(0)
 	for _, n := range stat {
 		rio += n.GetReadBytes()
 		wio += n.GetWriteBytes()
 	}
-	if !c.first {
-		c.first = true
-	} else {
-		c.rio.set(float64(rio - c.lastrio))
-		c.wio.set(float64(wio - c.lastwio))
-	}
-	c.lastrio = rio
-	c.lastwio = wio
+	c.rio.set(c.rioc.Update(now, float64(rio)))
+	c.wio.set(c.wioc.Update(now, float64(wio)))
 	return nil
 }
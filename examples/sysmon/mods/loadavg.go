@@ -17,9 +17,9 @@ func (c *LoadAvg) Len() int {
 // Data returns a slice of all Datasets available.
 func (c *LoadAvg) Data() []Dataset {
 	return []Dataset{
-		{"1 Minute", c.m1.values},
-		{"5 Minute", c.m5.values},
-		{"15 Minute", c.m15.values},
+		{"1 Minute", c.m1.values, ""},
+		{"5 Minute", c.m5.values, ""},
+		{"15 Minute", c.m15.values, ""},
 	}
 }
 
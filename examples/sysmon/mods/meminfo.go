@@ -17,7 +17,7 @@ func (c *MemInfo) Len() int {
 // Data returns a slice of all Datasets available.
 func (c *MemInfo) Data() []Dataset {
 	return []Dataset{
-		{"MEM Free", c.free.values},
+		{"MEM Free", c.free.values, ""},
 	}
 }
 
@@ -6,6 +6,7 @@ const maxKeep = 900
 type Dataset struct {
 	Title  string
 	Values []float64
+	Unit   string
 }
 
 // Collector is the interface used to descrbie monitoring/updater methods.
@@ -4,15 +4,13 @@ import (
 	"time"
 
 	"github.com/c9s/goprocinfo/linux"
+	"github.com/tomarus/chart/data"
 )
 
 // NetDev defines global cpu usage. Idle, system and user cpu time are supported.
 type NetDev struct {
 	rx, tx   store
-	lastrx   uint64
-	lasttx   uint64
-	first    bool
-	lasttime time.Time
+	rxc, txc data.Counter
 }
 
 // Len returns the amount of datapoints of the data available.
@@ -23,8 +21,8 @@ func (c *NetDev) Len() int {
 // Data returns a slice of all Datasets available.
 func (c *NetDev) Data() []Dataset {
 	return []Dataset{
-		{"RX Bytes", c.rx.values},
-		{"TX Bytes", c.tx.values},
+		{"RX Bytes", c.rx.values, "B/s"},
+		{"TX Bytes", c.tx.values, "B/s"},
 	}
 }
 
@@ -35,21 +33,13 @@ func (c *NetDev) Update() error {
 		return err
 	}
 	now := time.Now()
-	tdelta := float64(now.Sub(c.lasttime)) / 1e9
-	c.lasttime = now
 
 	tx, rx := uint64(0), uint64(0)
 	for _, n := range stat {
 		tx += n.TxBytes
 		rx += n.RxBytes
 	}
-	if !c.first {
-		c.first = true
-	} else {
-		c.tx.set(float64(tx-c.lasttx) / tdelta)
-		c.rx.set(float64(rx-c.lastrx) / tdelta)
-	}
-	c.lastrx = rx
-	c.lasttx = tx
+	c.tx.set(c.txc.Update(now, float64(tx)))
+	c.rx.set(c.rxc.Update(now, float64(rx)))
 	return nil
 }
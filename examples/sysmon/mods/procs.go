@@ -17,8 +17,8 @@ func (c *Procs) Len() int {
 // Data returns a slice of all Datasets available.
 func (c *Procs) Data() []Dataset {
 	return []Dataset{
-		{"Total Procs", c.tot.values},
-		{"Running Procs", c.run.values},
+		{"Total Procs", c.tot.values, ""},
+		{"Running Procs", c.run.values, ""},
 	}
 }
 
@@ -2,6 +2,11 @@ package image
 
 import (
 	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/tomarus/chart/data"
 	"github.com/tomarus/chart/palette"
@@ -20,8 +25,10 @@ const (
 
 // Image defines the interface for image (svg/png) backends.
 type Image interface {
-	// Start initializes a new image and sets the defaults.
-	Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection)
+	// Start initializes a new image and sets the defaults. The chart area of
+	// w by h pixels is drawn at margins mx and my, l contains the image size
+	// and the legend.
+	Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *Layout)
 
 	// End finishes and writes the image to the output writer.
 	End() error
@@ -32,6 +39,14 @@ type Image interface {
 	// Text writes a string to the image.
 	Text(color, align string, role TextRole, x, y int, txt string)
 
+	// Font sets the font used for text with role. Zero fields of f keep
+	// the default of the image.
+	Font(role TextRole, f Font) error
+
+	// MeasureText returns the width and height in pixels of a string.
+	// It can be called before Start.
+	MeasureText(role TextRole, txt string) (w, h int)
+
 	// TextID writes a string to the image using an id.
 	TextID(id, color, align string, role TextRole, x, y int, txt string)
 
@@ -39,9 +54,112 @@ type Image interface {
 	// only draw horizontal or vertical lines.
 	Line(color string, x1, y1, x2, y2 int)
 
-	// Legend draws the legend in the image using base for SI formatting.
-	Legend(float64)
+	// Legend draws the legend of the layout passed to Start.
+	Legend()
 
 	// Border draws a border around the chart area.
 	Border(x, y, w, h int)
 }
+
+// Scaler is implemented by images which can render at more than one device
+// pixel per chart pixel, like png.
+type Scaler interface {
+	SetScale(s float64)
+}
+
+// Fitter is implemented by images with a fixed size, like term. The chart
+// area is sized so the whole image, including the margins and the legend,
+// fits the size returned by Fit.
+type Fitter interface {
+	Fit() (w, h int)
+}
+
+// Animator is implemented by images which can write animations, like png.
+// AddFrame adds the drawn chart as the next frame instead of ending the
+// image, EndAnimation writes all frames, each shown for delay.
+type Animator interface {
+	AddFrame() error
+	EndAnimation(delay time.Duration) error
+}
+
+// Font configures the font used for a TextRole.
+type Font struct {
+	Family string  // font family, e.g. "menlo" in svg or "mono", "sans" or "smallcaps" in png
+	Size   float64 // size in pixels
+	Weight string  // "normal" or "bold"
+	File   string  // TrueType font file used by png, overrides Family and Weight
+}
+
+// CSS returns the family and weight of the font with all characters removed
+// which are not letters, digits, spaces, dashes, underscores or commas, so
+// they can be written into style sheets.
+func (f Font) CSS() (family, weight string) {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(" -_,", r) {
+				return r
+			}
+			return -1
+		}, s)
+	}
+	return clean(f.Family), clean(f.Weight)
+}
+
+// TextMetrics keeps the fonts of images which don't draw the text
+// themselves, like svg, where the browser draws it. Embedding it implements
+// Font and MeasureText of Image.
+type TextMetrics struct {
+	Fonts map[TextRole]Font
+}
+
+// NewTextMetrics returns text metrics with the default fonts.
+func NewTextMetrics() TextMetrics {
+	return TextMetrics{
+		Fonts: map[TextRole]Font{
+			TitleRole: {Family: "menlo", Size: 18, Weight: "normal"},
+			GridRole:  {Family: "menlo", Size: 13, Weight: "normal"},
+		},
+	}
+}
+
+// Font sets the font used for text with role. The file is not used.
+func (m *TextMetrics) Font(role TextRole, f Font) error {
+	cur := m.Fonts[role]
+	if f.Family != "" {
+		cur.Family = f.Family
+	}
+	if f.Size > 0 {
+		cur.Size = f.Size
+	}
+	if f.Weight != "" {
+		cur.Weight = f.Weight
+	}
+	m.Fonts[role] = cur
+	return nil
+}
+
+// MeasureText returns the estimated width and height in pixels of a string.
+// The actual size depends on the fonts available to whatever draws the
+// text, the estimate assumes a monospaced font.
+func (m *TextMetrics) MeasureText(role TextRole, txt string) (w, h int) {
+	size := m.Fonts[role].Size
+	return int(math.Ceil(float64(utf8.RuneCountInString(txt)) * size * .6)), int(math.Ceil(size * 1.2))
+}
+
+// Layout is the result of the chart layout pass.
+type Layout struct {
+	Width, Height    int // image size
+	Legend           *Legend
+	XLabels, YLabels Labels // format of the axis labels
+}
+
+// Labels describes how the labels of an axis are formatted, for images which
+// update the labels themselves, like live svg charts. Format is "time", "si"
+// or "float", it is empty for axes with a custom formatter.
+type Labels struct {
+	Format   string `json:"format"`
+	Layout   string `json:"layout,omitempty"`   // Go time layout of time labels
+	Base     int    `json:"base,omitempty"`     // base of si labels
+	Decimals int    `json:"decimals,omitempty"` // decimals of float labels
+	Unit     string `json:"unit,omitempty"`
+}
@@ -0,0 +1,191 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/format"
+)
+
+// Legend positions.
+const (
+	LegendBottom = "bottom"
+	LegendTop    = "top"
+	LegendRight  = "right"
+	LegendHidden = "hidden"
+)
+
+// DefaultLegend are the statistics shown in the legend by default.
+var DefaultLegend = []string{"min", "max", "avg"}
+
+const (
+	legendRow     = 16 // height of a legend row
+	legendSwatch  = 20 // width of the color box including spacing
+	legendGap     = 16 // space between series columns
+	legendMaxRows = 10 // series per column before wrapping automatically
+)
+
+// LegendOptions configures the legend of a chart.
+type LegendOptions struct {
+	Position string                          // "bottom" (default), "top", "right" or "hidden"
+	Columns  int                             // number of series columns, 0 wraps automatically
+	Stats    []string                        // statistics columns, see data.Data.Stat
+	Format   map[string]func(float64) string // custom formatters by statistic name
+	Series   []func(float64) string          // formatter of each dataset, e.g. its Y axis
+	Base     float64                         // SI base used when no other formatter is set
+}
+
+// Legend is the layout of a chart legend. It is calculated by the chart
+// using NewLegend and drawn by the image backends.
+type Legend struct {
+	Position      string
+	X, Y          int // top left corner of the legend
+	Width, Height int
+	Columns, Rows int // number of series columns and rows
+	ColWidth      int // width of a single series column
+
+	header string
+	stats  []string
+	names  []string   // statistics, e.g. "min" or "p95"
+	cells  [][]string // formatted statistics of each dataset
+}
+
+// NewLegend calculates the legend layout for the datasets d drawn on a chart
+// area of w by h pixels at margins mx and my. Text is measured using img.
+// A legend on top is placed at my, the chart area should be moved down by
+// its Height.
+func NewLegend(o *LegendOptions, img Image, d data.Collection, w, h, mx, my int) *Legend {
+	l := &Legend{Position: o.Position}
+	if l.Position == "" {
+		l.Position = LegendBottom
+	}
+	if l.Position == LegendHidden || len(d) == 0 {
+		l.Position = LegendHidden
+		return l
+	}
+
+	// Format all statistics and calculate the width of each column.
+	widths := make([]int, len(o.Stats))
+	cells := make([][]string, len(d))
+	names := make([]string, len(o.Stats))
+	for j, st := range o.Stats {
+		names[j] = strings.ToUpper(st[:1]) + st[1:]
+		widths[j] = utf8.RuneCountInString(names[j])
+	}
+	title := 0
+	for i := range d {
+		if tw, _ := img.MeasureText(GridRole, d[i].Title); tw > title {
+			title = tw
+		}
+		cells[i] = make([]string, len(o.Stats))
+		for j, st := range o.Stats {
+			v, _ := d[i].Stat(st)
+			if f, ok := o.Format[st]; ok {
+				cells[i][j] = f(v)
+			} else if st != "count" && i < len(o.Series) {
+				cells[i][j] = o.Series[i](v)
+			} else {
+				cells[i][j] = format.SI(v, 1, o.Base, "", "", "")
+			}
+			if n := utf8.RuneCountInString(cells[i][j]); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+	l.names = o.Stats
+	l.cells = cells
+	l.header = columns(names, widths)
+	l.stats = make([]string, len(d))
+	for i := range cells {
+		l.stats[i] = columns(cells[i], widths)
+	}
+	sw, _ := img.MeasureText(GridRole, "  "+l.header)
+	entry := legendSwatch + title + sw
+
+	n := len(d)
+	l.Columns = o.Columns
+	switch l.Position {
+	case LegendRight:
+		if l.Columns <= 0 {
+			rows := (h+my)/legendRow - 1
+			if rows < 1 {
+				rows = 1
+			}
+			l.Columns = (n + rows - 1) / rows
+		}
+		l.ColWidth = entry
+		l.Width = l.Columns*entry + (l.Columns-1)*legendGap
+		l.X = mx + w + legendGap
+		l.Y = my
+	default:
+		if l.Columns <= 0 {
+			l.Columns = (n + legendMaxRows - 1) / legendMaxRows
+			if fit := (w + legendGap) / (entry + legendGap); l.Columns > fit {
+				l.Columns = fit
+			}
+		}
+		if l.Columns < 1 {
+			l.Columns = 1
+		}
+		l.ColWidth = (w - (l.Columns-1)*legendGap) / l.Columns
+		l.Width = w
+		l.X = mx
+		l.Y = h + 2*my
+		if l.Position == LegendTop {
+			l.Y = my
+		}
+	}
+	if l.Columns > n {
+		l.Columns = n
+	}
+	l.Rows = (n + l.Columns - 1) / l.Columns
+	l.Height = (l.Rows + 1) * legendRow
+	return l
+}
+
+// columns right aligns all cells to their column widths in runes.
+func columns(cells []string, widths []int) string {
+	s := make([]string, len(cells))
+	for i := range cells {
+		s[i] = fmt.Sprintf("%*s", widths[i], cells[i])
+	}
+	return strings.Join(s, "  ")
+}
+
+// Visible reports whether the legend should be drawn.
+func (l *Legend) Visible() bool {
+	return l != nil && l.Position != LegendHidden
+}
+
+// Header returns the header text of the statistics columns.
+func (l *Legend) Header() string {
+	return l.header
+}
+
+// Stats returns the formatted statistics of dataset i.
+func (l *Legend) Stats(i int) string {
+	return l.stats[i]
+}
+
+// Statistics returns the names of the statistics columns, e.g. "min" or "p95".
+func (l *Legend) Statistics() []string {
+	return l.names
+}
+
+// Cells returns the formatted statistics of dataset i, one for each column.
+func (l *Legend) Cells(i int) []string {
+	return l.cells[i]
+}
+
+// Column returns the left position of series column c. The header of each
+// column is drawn at Y.
+func (l *Legend) Column(c int) int {
+	return l.X + c*(l.ColWidth+legendGap)
+}
+
+// Entry returns the top left position of the legend entry of dataset i.
+func (l *Legend) Entry(i int) (x, y int) {
+	return l.Column(i / l.Rows), l.Y + (i%l.Rows+1)*legendRow
+}
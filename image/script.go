@@ -0,0 +1,221 @@
+package image
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tomarus/chart/data"
+)
+
+// Script is the javascript shared by the interactive svg and html canvas
+// charts: the formatting of values and times, the moving average and the
+// csv and json export. The script of the image declares the chart constants
+// and state it uses, like data, raw, w, h, start, end, xfmt, yfmt, tz,
+// tzname, active and mav, and the functions ma, status and exportrange
+// which depend on how it draws.
+const Script = `
+let dopt = {year: "numeric", month: "2-digit", day: "2-digit", hour: "2-digit", minute: "2-digit", hour12: false}
+const months = ['January', 'February', 'March', 'April', 'May', 'June', 'July', 'August', 'September', 'October', 'November', 'December']
+const days = ['Sunday', 'Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday']
+function fmt(b) {
+	if (b < 1000000) return b.toFixed()
+	let sizes = ['', 'K', 'M', 'G', 'T', 'P']
+	let i = Math.floor(Math.log(b) / Math.log(1000))
+	return parseFloat((b / Math.pow(1000, i))).toFixed(3) + '' + sizes[i]
+}
+function fmtu(v, d) {
+	return label(v, yfmt, fmt) + (d.unit || yfmt.unit || '')
+}
+function clock(t) {
+	return new Date(t*1000).toLocaleTimeString([], {hour: '2-digit', minute: '2-digit', hour12: false})
+}
+// label formats v like the labels of the axis with format f, axes with a
+// custom Go formatter use the other function.
+function label(v, f, other) {
+	switch (f.format) {
+	case 'time': return golayout(v, f.layout)
+	case 'si': return si(v, f.base)
+	case 'float': return v.toFixed(f.decimals || 0)
+	}
+	return other(v)
+}
+function si(v, k) {
+	let av = Math.abs(v)
+	if (av < k) return v.toFixed(av < 10 ? 2 : av < 100 ? 1 : 0)
+	let i = Math.floor(Math.log(v) / Math.log(k)) || 0
+	return (v / Math.pow(k, i)).toFixed(1) + ['', 'K', 'M', 'G', 'T', 'P', 'E'][i]
+}
+// golayout formats the epoch t using a Go time layout in the time zone of
+// the chart.
+function golayout(t, l) {
+	let d = new Date((Math.trunc(t) + tz) * 1000)
+	let p = (n, w) => String(n).padStart(w || 2, '0')
+	let y = d.getUTCFullYear(), mo = d.getUTCMonth(), dd = d.getUTCDate(), hh = d.getUTCHours()
+	let off = (tz < 0 ? '-' : '+') + p(Math.floor(Math.abs(tz)/3600)) + ':' + p(Math.floor(Math.abs(tz)/60)%60)
+	return l.replace(/January|Jan(?![a-z])|Monday|Mon(?![a-z])|MST|2006|002|0[1-6]|15|_2|[-Z]07(:?00)?|PM|pm|[.,](0+|9+)(?![0-9])|[1-5]/g, s => {
+		switch (s) {
+		case 'January': return months[mo]
+		case 'Jan': return months[mo].slice(0, 3)
+		case 'Monday': return days[d.getUTCDay()]
+		case 'Mon': return days[d.getUTCDay()].slice(0, 3)
+		case 'MST': return tzname
+		case '2006': return y
+		case '06': return p(y%100)
+		case '002': return p(Math.floor((d - Date.UTC(y, 0, 1)) / 864e5) + 1, 3)
+		case '01': return p(mo+1)
+		case '1': return mo+1
+		case '02': return p(dd)
+		case '2': return dd
+		case '_2': return String(dd).padStart(2)
+		case '15': return p(hh)
+		case '03': return p(hh%12 || 12)
+		case '3': return hh%12 || 12
+		case '04': return p(d.getUTCMinutes())
+		case '4': return d.getUTCMinutes()
+		case '05': return p(d.getUTCSeconds())
+		case '5': return d.getUTCSeconds()
+		case 'PM': return hh < 12 ? 'AM' : 'PM'
+		case 'pm': return hh < 12 ? 'am' : 'pm'
+		}
+		if (s[0] === 'Z' && tz === 0) return 'Z'
+		if (s[1] === '0' && s[2] === '7') return s.length === 3 ? off.slice(0, 3) : s.includes(':') ? off : off.replace(':', '')
+		return s[1] === '0' ? s : '' // fractional seconds, labels are whole seconds
+	})
+}
+function fmtime(t) {
+	let d = Math.floor(t/86400)
+	let h = Math.floor(t/3600)%24
+	let m = Math.floor(t/60)%60
+	return (d>0?d+'d ':'')+(h>0?h+'h ':'')+(m>0?m+'m':'')
+}
+function smooth(vals, s) {
+	let size = Math.max(1, s.window)
+	let ok = (v) => v !== null && v !== undefined && v >= s.min
+	let res = []
+	if (s.method === 'ema') {
+		let a = 2 / (size+1), e = null
+		vals.forEach(v => {
+			if (ok(v)) e = e === null ? v : a*v + (1-a)*e
+			res.push(e)
+		})
+		return res
+	}
+	if (s.method === 'median') {
+		let half = Math.floor(Math.min(size, vals.length)/2)
+		for (let i=0; i<vals.length; i++) {
+			let win = []
+			for (let j=i-half; j<=i+half; j++) {
+				if (j>=0 && j<vals.length && ok(vals[j])) win.push(vals[j])
+			}
+			win.sort((a, b) => a-b)
+			let k = win.length
+			res.push(k === 0 ? null : k%2 ? win[(k-1)/2] : (win[k/2-1]+win[k/2])/2)
+		}
+		return res
+	}
+	if (s.method === 'sma' || s.method === 'wma') {
+		if (size >= vals.length) {
+			let sum = 0, n = 0
+			vals.forEach(v => { if (ok(v)) { sum += v; n++ } })
+			return vals.map(() => n ? sum/n : null)
+		}
+		let half = Math.floor(size/2)
+		for (let i=0; i<vals.length; i++) {
+			let sum = 0, tw = 0
+			for (let j=-half; j<=half; j++) {
+				if (i+j<0 || i+j>=vals.length || !ok(vals[i+j])) continue
+				let wx = s.method === 'wma' ? half+1-Math.abs(j) : 1
+				sum += vals[i+j]*wx
+				tw += wx
+			}
+			res.push(tw ? sum/tw : null)
+		}
+		return res
+	}
+	return vals.slice()
+}
+function smoothing(n) {
+	let s = data[n].smooth
+	return {method: s.method || 'wma', window: Math.min(1<<mav, w), min: s.min}
+}
+function topixels(vals, fmax) {
+	return vals.map(v => v === null ? missing : fmax ? Math.trunc(v*h/fmax) : 0)
+}
+function maclick() {
+	if (mav === w || 1<<mav > w) {
+		mav = -1
+	}
+	mav++
+	mavtxt = ""
+	if (mav>0) {
+		mavtxt = smoothing(active||0).method.toUpperCase() + ":" + (1<<mav > w ? "all" : 1<<mav)
+	}
+	ma(active||0)
+	status()
+}
+function exporttime(i) {
+	return new Date((end - start) / w * i + start).toISOString()
+}
+function exportname(ext) {
+	return 'chart-' + exporttime(0).replace(/[:.]/g, '') + '.' + ext
+}
+function csvquote(s) {
+	return '"' + String(s).replace(/"/g, '""') + '"'
+}
+function csv() {
+	let r = exportrange()
+	let out = 'time,' + r.series.map(n => csvquote(data[n].title)).join(',') + '\n'
+	for (let i=r.from; i<r.to; i++) {
+		out += exporttime(i) + ',' + r.series.map(n => raw[n][i] === null || raw[n][i] === undefined ? '' : raw[n][i]).join(',') + '\n'
+	}
+	download(exportname('csv'), 'text/csv', out)
+}
+function json() {
+	let r = exportrange()
+	let out = r.series.map(n => {
+		let points = []
+		for (let i=r.from; i<r.to; i++) {
+			points.push([exporttime(i), raw[n][i] === undefined ? null : raw[n][i]])
+		}
+		return {title: data[n].title, points: points}
+	})
+	download(exportname('json'), 'application/json', JSON.stringify(out))
+}
+function download(name, type, body) {
+	let a = document.createElementNS('http://www.w3.org/1999/xhtml', 'a')
+	a.href = URL.createObjectURL(new Blob([body], {type: type}))
+	a.download = name
+	document.documentElement.appendChild(a)
+	a.click()
+	a.remove()
+	setTimeout(() => URL.revokeObjectURL(a.href), 1000)
+}
+`
+
+// ScriptValues returns the raw values of all datasets as a javascript array
+// for the raw constant of the Script. NaN and infinite values are written as
+// null.
+func ScriptValues(d data.Collection) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, d := range d {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('[')
+		for j, v := range d.Raw() {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				b.WriteString("null")
+				continue
+			}
+			b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		b.WriteByte(']')
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -85,14 +85,15 @@ func (p *Palette) GetAlpha(name string) float64 {
 	return colors.EightTo1(uint8(a))
 }
 
-// GetAxisColorName gets the color for the Nth datapoint.
+// GetAxisColorName gets the color for the Nth datapoint. Colors are
+// reused when there are more datapoints than axis colors.
 func (p *Palette) GetAxisColorName(id int) string {
-	return axisColors[id]
+	return axisColors[id%len(axisColors)]
 }
 
 // GetHexAxisColor gets the color for the Nth datapoint.
 func (p *Palette) GetHexAxisColor(id int) string {
-	return p.GetHexColor(axisColors[id])
+	return p.GetHexColor(p.GetAxisColorName(id))
 }
 
 func (p *Palette) installPalette(pal map[string]string) error {
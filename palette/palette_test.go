@@ -1,6 +1,10 @@
 package palette
 
-import "testing"
+import (
+	"image"
+	"image/color"
+	"testing"
+)
 
 func TestDefaultPalette(t *testing.T) {
 	pal, _ := NewPalette("") // defaults to "white"
@@ -62,3 +66,40 @@ func TestRandom(t *testing.T) {
 		t.Errorf("Area color should be #172828 is %s", c)
 	}
 }
+
+func TestQuantizer(t *testing.T) {
+	p, _ := NewPalette("white")
+	m := image.NewRGBA(image.Rect(0, 0, 300, 1))
+	for x := 0; x < 300; x++ {
+		m.Set(x, 0, color.RGBA{uint8(x), uint8(x / 2), 0, 255}) // 300 distinct colors
+	}
+	for x := 0; x < 10; x++ {
+		m.Set(x, 0, color.RGBA{1, 2, 3, 255}) // the most used color
+	}
+
+	pal := p.Quantizer().Quantize(make(color.Palette, 0, 16), m)
+	if len(pal) != 16 {
+		t.Fatalf("Expected 16 colors, got %d", len(pal))
+	}
+	has := func(c color.Color) bool {
+		for _, pc := range pal {
+			if color.RGBAModel.Convert(pc) == color.RGBAModel.Convert(c) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range []string{"background", "area", "color1", "color2", "color3"} {
+		if !has(p.GetColor(name)) {
+			t.Errorf("Expected palette color %s in quantized palette", name)
+		}
+	}
+	if !has(color.RGBA{1, 2, 3, 255}) {
+		t.Error("Expected the most used color in quantized palette")
+	}
+
+	img := Paletted(m, pal)
+	if img.ColorIndexAt(0, 0) != uint8(pal.Index(color.RGBA{1, 2, 3, 255})) {
+		t.Error("Expected the most used color to be mapped exactly")
+	}
+}
@@ -0,0 +1,119 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Quantizer returns a draw.Quantizer for images drawn using this palette.
+// The colors of the palette, as drawn over the background, are always kept
+// exactly. The remaining entries are the most used other colors of the
+// image, like antialiased text and lines.
+func (p *Palette) Quantizer() draw.Quantizer {
+	return &quantizer{p}
+}
+
+type quantizer struct {
+	p *Palette
+}
+
+// Quantize appends up to cap(pal) - len(pal) colors to pal, 256 colors if
+// pal has no capacity.
+func (q *quantizer) Quantize(pal color.Palette, m image.Image) color.Palette {
+	if cap(pal) == 0 {
+		pal = make(color.Palette, 0, 256)
+	}
+	seen := map[color.RGBA]bool{}
+	for _, c := range pal {
+		seen[color.RGBAModel.Convert(c).(color.RGBA)] = true
+	}
+	add := func(c color.RGBA) {
+		if !seen[c] && len(pal) < cap(pal) {
+			seen[c] = true
+			pal = append(pal, c)
+		}
+	}
+
+	// The palette colors are drawn over the background like the images do.
+	q.p.RLock()
+	names := make([]string, 0, len(q.p.palette))
+	for name := range q.p.palette {
+		names = append(names, name)
+	}
+	q.p.RUnlock()
+	sort.Strings(names)
+	bg := q.p.GetColor("background")
+	px := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	for _, name := range append([]string{"background"}, names...) {
+		px.Set(0, 0, bg)
+		draw.Draw(px, px.Bounds(), image.NewUniform(q.p.GetColor(name)), image.Point{}, draw.Over)
+		add(px.RGBAAt(0, 0))
+	}
+
+	// Fill up with the most used colors of the image.
+	hist := map[color.RGBA]int{}
+	b := m.Bounds()
+	if rgba, ok := m.(*image.RGBA); ok {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := rgba.Pix[rgba.PixOffset(b.Min.X, y):rgba.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				hist[color.RGBA{row[i], row[i+1], row[i+2], row[i+3]}]++
+			}
+		}
+	} else {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				hist[color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)]++
+			}
+		}
+	}
+	cols := make([]color.RGBA, 0, len(hist))
+	for c := range hist {
+		cols = append(cols, c)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		a, b := cols[i], cols[j]
+		if hist[a] != hist[b] {
+			return hist[a] > hist[b]
+		}
+		return key(a) < key(b)
+	})
+	for _, c := range cols {
+		add(c)
+	}
+	return pal
+}
+
+// key returns c as a single number, used to sort colors.
+func key(c color.RGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}
+
+// Paletted converts m to a paletted image using the nearest colors of pal,
+// without dithering. Charts consist of large areas of a few colors, which
+// makes the conversion fast.
+func Paletted(m image.Image, pal color.Palette) *image.Paletted {
+	b := m.Bounds()
+	dst := image.NewPaletted(b, pal)
+	index := map[color.RGBA]uint8{}
+	rgba, _ := m.(*image.RGBA)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var c color.RGBA
+			if rgba != nil {
+				c = rgba.RGBAAt(x, y)
+			} else {
+				c = color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)
+			}
+			i, ok := index[c]
+			if !ok {
+				i = uint8(pal.Index(c))
+				index[c] = i
+			}
+			dst.Pix[dst.PixOffset(x, y)] = i
+		}
+	}
+	return dst
+}
@@ -0,0 +1,76 @@
+package pdf
+
+import (
+	"math"
+
+	"github.com/tomarus/chart/image"
+)
+
+// families are the PDF core fonts by family. Core fonts are not embedded,
+// every PDF reader provides them.
+var families = map[string]string{
+	"sans":      "Helvetica",
+	"helvetica": "Helvetica",
+	"mono":      "Courier",
+	"courier":   "Courier",
+	"serif":     "Times",
+	"times":     "Times",
+}
+
+// Font sets the font used for text with role. The family can be one of the
+// core fonts "sans" (helvetica), "mono" (courier) or "serif" (times), other
+// families are ignored. A TrueType font file overrides the family, it is
+// embedded in the document.
+func (pdf *PDF) Font(role image.TextRole, f image.Font) error {
+	cur := pdf.fonts[role]
+	if _, ok := families[f.Family]; ok {
+		cur.Family = f.Family
+	}
+	if f.Size > 0 {
+		cur.Size = f.Size
+	}
+	if f.Weight != "" {
+		cur.Weight = f.Weight
+	}
+	if f.File != "" {
+		cur.File = f.File
+		if err := pdf.loadFont(cur.File); err != nil {
+			return err
+		}
+	}
+	pdf.fonts[role] = cur
+	return nil
+}
+
+// loadFont adds a TrueType font file to the document once, the file name
+// is used as its family.
+func (pdf *PDF) loadFont(file string) error {
+	pdf.doc.AddUTF8Font(file, "", file)
+	if err := pdf.doc.Error(); err != nil {
+		pdf.doc.ClearError()
+		return err
+	}
+	return nil
+}
+
+// face selects the font of role and returns txt translated to the encoding
+// of the font.
+func (pdf *PDF) face(role image.TextRole, txt string) string {
+	f := pdf.fonts[role]
+	if f.File != "" {
+		pdf.doc.SetFont(f.File, "", f.Size)
+		return txt
+	}
+	style := ""
+	if f.Weight == "bold" {
+		style = "B"
+	}
+	pdf.doc.SetFont(families[f.Family], style, f.Size)
+	return pdf.tr(txt)
+}
+
+// MeasureText returns the width and height in pixels of a string.
+func (pdf *PDF) MeasureText(role image.TextRole, txt string) (w, h int) {
+	txt = pdf.face(role, txt)
+	return int(math.Ceil(pdf.doc.GetStringWidth(txt))), int(math.Ceil(pdf.fonts[role].Size * 1.2))
+}
@@ -0,0 +1,264 @@
+// Package pdf provides the vector pdf interface for tomarus chart lib.
+// Use New for a document of a single chart or NewReport to lay out many
+// charts on pages.
+package pdf
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+// missing is the pixel value of missing values.
+const missing = data.Missing
+
+// PDF implements the chart interface to write PDF documents. One chart
+// pixel is one point (1/72 inch) in the document.
+type PDF struct {
+	w                io.Writer
+	doc              *gofpdf.Fpdf
+	tr               func(string) string // translates text to the encoding of the core fonts
+	report           *Report
+	data             data.Collection
+	width, height    int
+	marginx, marginy int
+	start, end       int64
+	pal              *palette.Palette
+	legend           *image.Legend
+	fonts            map[image.TextRole]image.Font
+}
+
+// New initializes a new pdf chart writer, the document contains a single
+// page of the size of the chart.
+func New() *PDF {
+	doc := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt"})
+	doc.SetCatalogSort(true)
+	return newPDF(doc, nil)
+}
+
+func newPDF(doc *gofpdf.Fpdf, r *Report) *PDF {
+	doc.SetCreator("github.com/tomarus/chart", false)
+	return &PDF{
+		doc:    doc,
+		tr:     doc.UnicodeTranslatorFromDescriptor(""),
+		report: r,
+		fonts: map[image.TextRole]image.Font{
+			image.TitleRole: {Family: "sans", Size: 14, Weight: "bold"},
+			image.GridRole:  {Family: "mono", Size: 10, Weight: "normal"},
+		},
+	}
+}
+
+// Start initializes a new image and sets the defaults.
+func (pdf *PDF) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *image.Layout) {
+	pdf.w = wr
+	pdf.data = d
+	pdf.width = w
+	pdf.height = h
+	pdf.marginx = mx
+	pdf.marginy = my
+	pdf.start = start
+	pdf.end = end
+	pdf.pal = p
+	pdf.legend = l.Legend
+
+	if pdf.report != nil {
+		pdf.report.place(float64(l.Width), float64(l.Height))
+	} else {
+		// The document is dated at the end of the chart, so the same chart
+		// always results in the same document.
+		pdf.doc.SetCreationDate(time.Unix(end, 0).UTC())
+		pdf.doc.SetModificationDate(time.Unix(end, 0).UTC())
+		pdf.doc.SetMargins(0, 0, 0)
+		pdf.doc.SetAutoPageBreak(false, 0)
+		pdf.doc.AddPageFormat("P", gofpdf.SizeType{Wd: float64(l.Width), Ht: float64(l.Height)})
+	}
+	pdf.doc.SetLineCapStyle("square")
+	pdf.fill("background", 0, 0, float64(l.Width), float64(l.Height))
+}
+
+// End finishes and writes the document to the output writer. Charts of a
+// report are written by Report.Close.
+func (pdf *PDF) End() error {
+	if pdf.report != nil {
+		pdf.doc.TransformEnd()
+		return pdf.doc.Error()
+	}
+	return pdf.doc.Output(pdf.w)
+}
+
+// color sets the fill, stroke and text color to the palette color c.
+func (pdf *PDF) color(c color.Color) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	pdf.doc.SetFillColor(int(n.R), int(n.G), int(n.B))
+	pdf.doc.SetDrawColor(int(n.R), int(n.G), int(n.B))
+	pdf.doc.SetTextColor(int(n.R), int(n.G), int(n.B))
+	pdf.doc.SetAlpha(float64(n.A)/255, "Normal")
+}
+
+// fill fills a rectangle using the color name from the palette.
+func (pdf *PDF) fill(name string, x, y, w, h float64) {
+	pdf.color(pdf.pal.GetColor(name))
+	pdf.doc.Rect(x, y, w, h, "F")
+}
+
+// Graph renders all chart dataset values to the visible chart area.
+// Each area is drawn as a single polygon of one pixel wide columns.
+func (pdf *PDF) Graph() error {
+	for pt, d := range pdf.data {
+		col := pdf.pal.GetColor(pdf.pal.GetAxisColorName(pt))
+		a := float64(d.NMax) / float64(pdf.height)
+		b := float64(d.NMax) - a*float64(pdf.height)
+		if len(d.High) > 0 {
+			pdf.envelope(col, d, a, b)
+			continue
+		}
+		for _, v := range d.Values {
+			if v < 0 && v != missing {
+				return fmt.Errorf("Negative values not supported")
+			}
+		}
+		bottom := make([]int, len(d.Values))
+		pdf.color(col)
+		pdf.area(d.Values, bottom, a, b)
+	}
+	for _, d := range pdf.data {
+		a := float64(d.NMax) / float64(pdf.height)
+		b := float64(d.NMax) - a*float64(pdf.height)
+		pdf.color(pdf.pal.GetColor("marker"))
+		pdf.polyline(2, d.Smoothed, a, b)
+	}
+	return nil
+}
+
+// envelope draws a shaded min-max band with the average line on top.
+func (pdf *PDF) envelope(col color.Color, d data.Data, a, b float64) {
+	pdf.color(col)
+	pdf.doc.SetAlpha(.35, "Normal")
+	pdf.area(d.High, d.Low, a, b)
+	pdf.color(col)
+	pdf.polyline(1, d.Values, a, b)
+}
+
+// y returns the vertical position of pixel value v scaled using a and b.
+func (pdf *PDF) y(v int, a, b float64) float64 {
+	return float64(pdf.height - int(float64(v)*a+b) + pdf.marginy)
+}
+
+// area fills the columns between the values top and bottom. Missing values
+// interrupt the area.
+func (pdf *PDF) area(top, bottom []int, a, b float64) {
+	var upper, lower []gofpdf.PointType
+	flush := func() {
+		if len(upper) > 0 {
+			for i := len(lower) - 1; i >= 0; i-- {
+				upper = append(upper, lower[i])
+			}
+			pdf.doc.Polygon(upper, "F")
+		}
+		upper, lower = upper[:0], lower[:0]
+	}
+	for i := range top {
+		if top[i] == missing || bottom[i] == missing {
+			flush()
+			continue
+		}
+		x := float64(i + pdf.marginx)
+		yt, yb := pdf.y(top[i], a, b), pdf.y(bottom[i], a, b)
+		upper = append(upper, gofpdf.PointType{X: x, Y: yt}, gofpdf.PointType{X: x + 1, Y: yt})
+		lower = append(lower, gofpdf.PointType{X: x, Y: yb}, gofpdf.PointType{X: x + 1, Y: yb})
+	}
+	flush()
+}
+
+// polyline draws a line through all values scaled using a and b in the
+// current color. Missing values interrupt the line.
+func (pdf *PDF) polyline(width float64, values []int, a, b float64) {
+	pdf.doc.SetDashPattern(nil, 0)
+	pdf.doc.SetLineWidth(width)
+	pen, path := false, false
+	for i, v := range values {
+		if v == missing {
+			pen = false
+			continue
+		}
+		x, y := float64(i+pdf.marginx)+.5, pdf.y(v, a, b)
+		if pen {
+			pdf.doc.LineTo(x, y)
+		} else {
+			pdf.doc.MoveTo(x, y)
+			pen, path = true, true
+		}
+	}
+	if path {
+		pdf.doc.DrawPath("D")
+	}
+}
+
+// Text writes a string to the image.
+func (pdf *PDF) Text(col, align string, role image.TextRole, x, y int, txt string) {
+	txt = pdf.face(role, txt)
+	fx := float64(x)
+	switch align {
+	case "middle", "center":
+		fx -= pdf.doc.GetStringWidth(txt) / 2
+	case "end", "right":
+		fx -= pdf.doc.GetStringWidth(txt)
+	}
+	pdf.color(pdf.pal.GetColor(col))
+	pdf.doc.Text(fx, float64(y), txt)
+}
+
+// TextID writes a string to the image.
+func (pdf *PDF) TextID(id, col, align string, role image.TextRole, x, y int, txt string) {
+	pdf.Text(col, align, role, x, y, txt)
+}
+
+// Line draws a line between the points using the color name from the
+// palette. Lines cover the same pixels as in png images, grid lines are
+// dotted.
+func (pdf *PDF) Line(col string, x1, y1, x2, y2 int) {
+	if col == "grid" || col == "grid2" {
+		pdf.doc.SetDashPattern([]float64{1, 1}, 0)
+	} else {
+		pdf.doc.SetDashPattern(nil, 0)
+	}
+	pdf.color(pdf.pal.GetColor(col))
+	pdf.doc.SetLineWidth(1)
+	pdf.doc.Line(float64(x1)+.5, float64(y1)+.5, float64(x2)+.5, float64(y2)+.5)
+}
+
+// Legend draws the pdf specific legend.
+func (pdf *PDF) Legend() {
+	l := pdf.legend
+	if !l.Visible() {
+		return
+	}
+	for c := 0; c < l.Columns; c++ {
+		pdf.Text("title2", "right", image.GridRole, l.Column(c)+l.ColWidth, l.Y+10, l.Header())
+	}
+
+	for i, d := range pdf.data {
+		x, y := l.Entry(i)
+		pdf.fill(pdf.pal.GetAxisColorName(i), float64(x), float64(y), 12, 12)
+		pdf.Text("title", "left", image.GridRole, x+20, y+10, d.Title)
+		pdf.Text("title", "right", image.GridRole, x+l.ColWidth, y+10, l.Stats(i))
+		pdf.Line("grid2", x, y+10+3, x+l.ColWidth, y+10+3)
+	}
+}
+
+// Border draws a border around the chart area.
+func (pdf *PDF) Border(x, y, w, h int) {
+	c := "border"
+	pdf.Line(c, x, y, x+w, y)
+	pdf.Line(c, x+w, y, x+w, y+h)
+	pdf.Line(c, x+w, y+h, x, y+h)
+	pdf.Line(c, x, y+h, x, y)
+}
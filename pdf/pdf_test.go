@@ -0,0 +1,48 @@
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReport(&out, "A4", "portrait")
+	if r.pagew != 595.28 || r.pageh != 841.89 {
+		t.Fatalf("Expected A4 page size, got %vx%v", r.pagew, r.pageh)
+	}
+
+	for _, c := range []struct {
+		w, h float64
+		x, y float64 // expected position of the chart
+	}{
+		{200, 100, 36, 36},
+		{200, 100, 36 + 200 + 18, 36},      // next to the first chart
+		{200, 150, 36, 36 + 100 + 18},      // does not fit in the row
+		{1046.56, 200, 36, 154 + 150 + 18}, // scaled to half the size
+		{200, 500, 36, 36},                 // next page
+	} {
+		r.place(c.w, c.h)
+		r.doc.TransformEnd()
+		if x, y := r.x-c.w*min(1, 523.28/c.w)-r.gap, r.y; x != c.x || y != c.y {
+			t.Errorf("Expected %vx%v chart at %v,%v, got %v,%v", c.w, c.h, c.x, c.y, x, y)
+		}
+	}
+	if n := r.doc.PageNo(); n != 2 {
+		t.Errorf("Expected 2 pages, got %d", n)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "%PDF-") {
+		t.Error("Expected a pdf document")
+	}
+}
+
+func min(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,123 @@
+package pdf
+
+import (
+	"io"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Report lays out multiple charts on the pages of a PDF document. Charts
+// are placed from left to right and top to bottom in the order they are
+// rendered, charts wider than a page are scaled down to fit.
+//
+//	r := pdf.NewReport(w, "A4", "portrait")
+//	r.Heading("Capacity")
+//	c, _ := chart.NewChart(&chart.Options{Image: r.Chart(), Width: 480, Height: 120})
+//	...
+//	c.Render()
+//	r.Close()
+type Report struct {
+	w            io.Writer
+	doc          *gofpdf.Fpdf
+	orientation  string
+	margin, gap  float64
+	pagew, pageh float64
+	x, y, rowh   float64 // current position and height of the current row
+	page         bool    // whether a page was added
+}
+
+// NewReport creates a report of pages of the named size, e.g. "A3", "A4",
+// "A5", "Letter" or "Legal", in "portrait" or "landscape" orientation.
+func NewReport(w io.Writer, size, orientation string) *Report {
+	o := "P"
+	if orientation == "landscape" {
+		o = "L"
+	}
+	doc := gofpdf.New(o, "pt", size, "")
+	doc.SetAutoPageBreak(false, 0)
+	doc.SetCatalogSort(true)
+	r := &Report{w: w, doc: doc, orientation: o, margin: 36, gap: 18}
+	r.pagew, r.pageh = doc.GetPageSize()
+	return r
+}
+
+// Margin sets the page margins in points, 36 (half an inch) by default.
+// Charts are spaced by half the margin.
+func (r *Report) Margin(m float64) *Report {
+	r.margin, r.gap = m, m/2
+	return r
+}
+
+// Chart returns an image for the next chart of the report. Fonts set on
+// the image are added to the document once.
+func (r *Report) Chart() *PDF {
+	return newPDF(r.doc, r)
+}
+
+// Heading starts a new row with a heading across the page.
+func (r *Report) Heading(txt string) *Report {
+	r.newRow()
+	const size = 16
+	r.fit(size * 1.5)
+	r.doc.SetFont("Helvetica", "B", size)
+	r.doc.SetTextColor(0, 0, 0)
+	r.doc.SetAlpha(1, "Normal")
+	r.doc.Text(r.margin, r.y+size, r.doc.UnicodeTranslatorFromDescriptor("")(txt))
+	r.y += size * 1.5
+	return r
+}
+
+// NewPage continues the report on a new page.
+func (r *Report) NewPage() *Report {
+	r.doc.AddPage()
+	r.page = true
+	r.x, r.y, r.rowh = r.margin, r.margin, 0
+	return r
+}
+
+// Close writes the document to the output writer.
+func (r *Report) Close() error {
+	if !r.page {
+		r.NewPage()
+	}
+	return r.doc.Output(r.w)
+}
+
+// newRow moves the position to the start of the next row.
+func (r *Report) newRow() {
+	if r.x > r.margin {
+		r.x = r.margin
+		r.y += r.rowh + r.gap
+		r.rowh = 0
+	}
+}
+
+// fit starts a new page if there is no room for h points below the
+// current position.
+func (r *Report) fit(h float64) {
+	if !r.page || r.y > r.margin && r.y+h > r.pageh-r.margin {
+		r.NewPage()
+	}
+}
+
+// place reserves room for a chart of w by h points and transforms the
+// coordinates of the chart to it, until the chart ends.
+func (r *Report) place(w, h float64) {
+	s := 1.
+	if avail := r.pagew - 2*r.margin; w > avail {
+		s = avail / w
+	}
+	w, h = w*s, h*s
+	if r.x+w > r.pagew-r.margin {
+		r.newRow()
+	}
+	r.fit(h)
+
+	r.doc.TransformBegin()
+	r.doc.TransformTranslate(r.x, r.y)
+	r.doc.TransformScale(s*100, s*100, 0, 0)
+	r.x += w + r.gap
+	if h > r.rowh {
+		r.rowh = h
+	}
+}
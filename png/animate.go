@@ -0,0 +1,189 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	stdpng "image/png"
+	"io"
+	"math"
+	"time"
+
+	"github.com/tomarus/chart/palette"
+)
+
+// AddFrame adds the drawn image as the next frame of an animation. GIF
+// images are written as animated gif, the png encodings as animated png.
+// Indexed frames share the colors of the first frame, since all frames of
+// an animated png use the same palette.
+func (png *PNG) AddFrame() error {
+	switch png.encoding {
+	case GIF:
+		pal := png.pal.Quantizer().Quantize(make(color.Palette, 0, 256), png.img)
+		png.frames = append(png.frames, palette.Paletted(png.img, pal))
+		return nil
+	case JPEG:
+		return fmt.Errorf("jpeg images can't be animated")
+	}
+
+	var m image.Image = png.img
+	if png.encoding == Indexed {
+		if png.framepal == nil {
+			png.framepal = png.pal.Quantizer().Quantize(make(color.Palette, 0, 256), png.img)
+		}
+		m = palette.Paletted(png.img, png.framepal)
+	}
+	var buf bytes.Buffer
+	enc := stdpng.Encoder{CompressionLevel: stdpng.BestCompression}
+	if err := enc.Encode(&buf, m); err != nil {
+		return err
+	}
+	head, idat, err := chunks(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if png.head == nil {
+		png.head = head
+	} else if !bytes.Equal(head[0], png.head[0]) {
+		return fmt.Errorf("frames differ in size or color type")
+	}
+	png.idat = append(png.idat, idat)
+	return nil
+}
+
+// EndAnimation writes all frames to the output writer, each frame is shown
+// for delay. The animation loops forever. Gif delays are at least 20ms,
+// since browsers show frames with shorter delays for 100ms.
+func (png *PNG) EndAnimation(delay time.Duration) error {
+	if png.encoding == GIF {
+		if len(png.frames) == 0 {
+			return fmt.Errorf("no frames available")
+		}
+		anim := &gif.GIF{Image: png.frames, Delay: make([]int, len(png.frames))}
+		for i := range anim.Delay {
+			anim.Delay[i] = gifDelay(delay)
+		}
+		return gif.EncodeAll(png.w, anim)
+	}
+	if len(png.idat) == 0 {
+		return fmt.Errorf("no frames available")
+	}
+	return png.writeAPNG(delay)
+}
+
+// gifDelay returns the delay in hundredths of a second, between 2 and the
+// maximum of the gif delay field.
+func gifDelay(d time.Duration) int {
+	cs := int64(math.Round(d.Seconds() * 100))
+	if cs < 2 {
+		return 2
+	}
+	if cs > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return int(cs)
+}
+
+// apngDelay returns the delay as a fraction of seconds. The denominator is
+// the most precise one for which the numerator fits the frame control.
+func apngDelay(d time.Duration) (num, den uint16) {
+	for _, k := range []uint16{1000, 100, 10, 1} {
+		if n := math.Round(d.Seconds() * float64(k)); n <= math.MaxUint16 {
+			return uint16(n), k
+		}
+	}
+	return math.MaxUint16, 1
+}
+
+// writeAPNG writes the collected frames as animated png. The first frame is
+// the default image, shown by decoders which don't support animations.
+func (png *PNG) writeAPNG(delay time.Duration) error {
+	w := &chunkWriter{w: png.w}
+	w.raw([]byte("\x89PNG\r\n\x1a\n"))
+
+	// IHDR must come first, the palette chunks follow the animation control.
+	w.raw(png.head[0])
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(png.idat)))
+	w.chunk("acTL", actl)
+	for _, c := range png.head[1:] {
+		w.raw(c)
+	}
+
+	width := binary.BigEndian.Uint32(png.head[0][8:])
+	height := binary.BigEndian.Uint32(png.head[0][12:])
+	seq := uint32(0)
+	for i, idat := range png.idat {
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], width)
+		binary.BigEndian.PutUint32(fctl[8:], height)
+		num, den := apngDelay(delay)
+		binary.BigEndian.PutUint16(fctl[20:], num)
+		binary.BigEndian.PutUint16(fctl[22:], den)
+		w.chunk("fcTL", fctl)
+		seq++
+		if i == 0 {
+			w.chunk("IDAT", idat)
+			continue
+		}
+		fdat := make([]byte, 4, 4+len(idat))
+		binary.BigEndian.PutUint32(fdat, seq)
+		w.chunk("fdAT", append(fdat, idat...))
+		seq++
+	}
+	w.chunk("IEND", nil)
+	return w.err
+}
+
+// chunks splits an encoded png in the raw header chunks before the image
+// data, like IHDR and PLTE, and the concatenated image data.
+func chunks(b []byte) (head [][]byte, idat []byte, err error) {
+	b = b[8:]
+	for len(b) >= 12 {
+		n := int(binary.BigEndian.Uint32(b))
+		if len(b) < 12+n {
+			break
+		}
+		switch typ := string(b[4:8]); typ {
+		case "IDAT":
+			idat = append(idat, b[8:8+n]...)
+		case "IEND":
+		default:
+			head = append(head, b[:12+n])
+		}
+		b = b[12+n:]
+	}
+	if len(head) == 0 || string(head[0][4:8]) != "IHDR" || idat == nil {
+		return nil, nil, fmt.Errorf("invalid png frame")
+	}
+	return head, idat, nil
+}
+
+// chunkWriter writes png chunks, keeping the first error.
+type chunkWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *chunkWriter) raw(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+func (w *chunkWriter) chunk(typ string, data []byte) {
+	hdr := make([]byte, 8)
+	binary.BigEndian.PutUint32(hdr, uint32(len(data)))
+	copy(hdr[4:], typ)
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(data)
+	w.raw(hdr)
+	w.raw(data)
+	w.raw(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+}
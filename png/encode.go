@@ -0,0 +1,65 @@
+package png
+
+import (
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	stdpng "image/png"
+
+	"github.com/tomarus/chart/palette"
+)
+
+// Encodings of the rendered image.
+const (
+	TrueColor = "png"     // 32 bit png, the default
+	Indexed   = "indexed" // png of at most 256 colors
+	GIF       = "gif"     // gif of at most 256 colors
+	JPEG      = "jpeg"    // jpeg, see Quality
+)
+
+// Encoding sets the encoding of the image written by End. Indexed and GIF
+// images use the colors of the chart palette and the most used other
+// colors, like antialiased text, which makes small charts a fraction of
+// the size of a truecolor png.
+func (png *PNG) Encoding(e string) *PNG {
+	png.encoding = e
+	return png
+}
+
+// Quality sets the quality (1-100) of jpeg images, 90 by default.
+func (png *PNG) Quality(q int) *PNG {
+	png.quality = q
+	return png
+}
+
+// ContentType returns the mime type of the encoding.
+func (png *PNG) ContentType() string {
+	switch png.encoding {
+	case GIF:
+		return "image/gif"
+	case JPEG:
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// End finishes and writes the image to the output writer.
+func (png *PNG) End() error {
+	switch png.encoding {
+	case Indexed, GIF:
+		pal := png.pal.Quantizer().Quantize(make(color.Palette, 0, 256), png.img)
+		img := palette.Paletted(png.img, pal)
+		if png.encoding == GIF {
+			return gif.Encode(png.w, img, nil)
+		}
+		enc := stdpng.Encoder{CompressionLevel: stdpng.BestCompression}
+		return enc.Encode(png.w, img)
+	case JPEG:
+		q := png.quality
+		if q <= 0 {
+			q = 90
+		}
+		return jpeg.Encode(png.w, png.img, &jpeg.Options{Quality: q})
+	}
+	return png.gg.EncodePNG(png.w)
+}
@@ -0,0 +1,110 @@
+package png
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/gofont/gosmallcaps"
+
+	myimg "github.com/tomarus/chart/image"
+)
+
+// families are the embedded Go fonts by family and weight. There is no
+// bold Go smallcaps font.
+var families = map[string][]byte{
+	"mono":      gomono.TTF,
+	"mono bold": gomonobold.TTF,
+	"sans":      goregular.TTF,
+	"sans bold": gobold.TTF,
+	"smallcaps": gosmallcaps.TTF,
+}
+
+// parsed caches parsed fonts by family and weight or file name.
+// Parsed fonts are read only and can be shared by all images.
+var parsed sync.Map
+
+// loadFont returns the parsed font for f.
+func loadFont(f myimg.Font) (*truetype.Font, error) {
+	key := f.File
+	if key == "" {
+		key = f.Family
+		if f.Weight == "bold" {
+			key += " bold"
+		}
+	}
+	if ttf, ok := parsed.Load(key); ok {
+		return ttf.(*truetype.Font), nil
+	}
+
+	b := families[key]
+	if f.File != "" {
+		var err error
+		b, err = os.ReadFile(f.File)
+		if err != nil {
+			return nil, err
+		}
+	} else if b == nil {
+		if _, ok := families[f.Family]; ok {
+			return nil, fmt.Errorf("font family %q has no %s weight", f.Family, f.Weight)
+		}
+		return nil, fmt.Errorf("unknown font family %q", f.Family)
+	}
+	ttf, err := truetype.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	parsed.Store(key, ttf)
+	return ttf, nil
+}
+
+// Font sets the font used for text with role. The family is one of the
+// embedded Go fonts "mono", "sans" or "smallcaps", which is not available in
+// bold. A TrueType font file overrides the family and weight.
+func (png *PNG) Font(role myimg.TextRole, f myimg.Font) error {
+	cur := png.fonts[role]
+	if f.Family != "" {
+		cur.Family = f.Family
+	}
+	if f.Size > 0 {
+		cur.Size = f.Size
+	}
+	if f.Weight != "" {
+		cur.Weight = f.Weight
+	}
+	if f.File != "" {
+		cur.File = f.File
+	}
+	if _, err := loadFont(cur); err != nil {
+		return err
+	}
+	png.fonts[role] = cur
+	delete(png.faces, role)
+	return nil
+}
+
+// fontFace returns the font face for role. Faces are cached per image
+// because they are not safe for concurrent use.
+func (png *PNG) fontFace(role myimg.TextRole) font.Face {
+	if face, ok := png.faces[role]; ok {
+		return face
+	}
+	f := png.fonts[role]
+	ttf, err := loadFont(f)
+	if err != nil {
+		panic(err) // only embedded fonts or fonts loaded by Font
+	}
+	face := truetype.NewFace(ttf, &truetype.Options{
+		Size:    f.Size * png.scale,
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+	png.faces[role] = face
+	return face
+}
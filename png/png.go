@@ -2,38 +2,73 @@ package png
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
 	"io"
+	"math"
 
 	"github.com/fogleman/gg"
-	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/gomono"
-	"golang.org/x/image/font/gofont/gosmallcapsitalic"
+	"golang.org/x/image/math/fixed"
 
 	"github.com/tomarus/chart/data"
-	"github.com/tomarus/chart/format"
 	myimg "github.com/tomarus/chart/image"
 	"github.com/tomarus/chart/palette"
 )
 
+// missing is the pixel value of missing values.
+const missing = data.Missing
+
 // PNG implements the chart interface to write PNG images.
 type PNG struct {
 	w                io.Writer
+	img              *image.RGBA
+	src              image.Uniform // source color of fill
 	gg               *gg.Context
 	data             data.Collection
 	width, height    int
 	marginx, marginy int
 	start, end       int64
 	pal              *palette.Palette
+	legend           *myimg.Legend
+	canvasw, canvash int
+	fonts            map[myimg.TextRole]myimg.Font
+	faces            map[myimg.TextRole]font.Face
+	scale            float64
+	encoding         string
+	quality          int
+	frames           []*image.Paletted // gif animation frames
+	framepal         color.Palette     // palette of indexed png animations
+	head             [][]byte          // png chunks before the image data of the first frame
+	idat             [][]byte          // png animation frames
 }
 
 // New initializes a new png chart image writer.
 func New() *PNG {
-	return &PNG{}
+	return &PNG{
+		fonts: map[myimg.TextRole]myimg.Font{
+			myimg.TitleRole: {Family: "smallcaps", Size: 15, Weight: "normal"},
+			myimg.GridRole:  {Family: "mono", Size: 12, Weight: "normal"},
+		},
+		faces: map[myimg.TextRole]font.Face{},
+		scale: 1,
+	}
+}
+
+// SetScale sets the number of device pixels per chart pixel, e.g. 2 for
+// HiDPI screens. Fonts, line widths and the image size are scaled, the
+// layout of the chart stays the same.
+func (png *PNG) SetScale(s float64) {
+	if s <= 0 {
+		s = 1
+	}
+	png.scale = s
+	png.faces = map[myimg.TextRole]font.Face{}
 }
 
 // Start initializes a new image and sets the defaults.
-func (png *PNG) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection) {
+func (png *PNG) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *myimg.Layout) {
 	png.w = wr
 	png.data = d
 	png.width = w
@@ -43,62 +78,179 @@ func (png *PNG) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palet
 	png.start = start
 	png.end = end
 	png.pal = p
+	png.legend = l.Legend
+	png.canvasw, png.canvash = l.Width, l.Height
 }
 
-// End finishes and writes the image to the output writer.
-func (png *PNG) End() error {
-	return png.gg.EncodePNG(png.w)
+// Image returns the rendered image, it is drawn by Graph and the chart
+// until End is called.
+func (png *PNG) Image() image.Image {
+	return png.img
 }
 
 // Graph renders all chart dataset values to the visible chart area.
 func (png *PNG) Graph() error {
-	png.gg = gg.NewContext(png.width+png.marginx+4, png.height+(2*png.marginy)+((png.data.Len()+1)*16))
-	png.gg.SetColor(png.pal.GetColor("background"))
-	png.gg.Clear()
+	s := png.scale
+	png.img = image.NewRGBA(image.Rect(0, 0, int(math.Ceil(float64(png.canvasw)*s)), int(math.Ceil(float64(png.canvash)*s))))
+	draw.Draw(png.img, png.img.Bounds(), image.NewUniform(png.pal.GetColor("background")), image.Point{}, draw.Src)
+	png.gg = gg.NewContextForRGBA(png.img)
+	png.gg.Scale(s, s)
 
+	// Area columns are filled directly into the image, which is much faster
+	// than stroking a line for each pixel.
 	for pt, data := range png.data {
-		col := png.pal.GetAxisColorName(pt)
+		col := png.pal.GetColor(png.pal.GetAxisColorName(pt))
 		a := float64(data.NMax) / float64(png.height)
 		b := float64(data.NMax) - a*float64(png.height)
+		if len(data.High) > 0 {
+			png.envelope(col, data, a, b)
+			continue
+		}
+		top := make([]int, len(data.Values))
 		for i := range data.Values {
+			if data.Values[i] == missing {
+				top[i] = png.height + png.marginy
+				continue
+			}
 			if data.Values[i] < 0 {
 				return fmt.Errorf("Negative values not supported")
 			}
 			v := int(float64(data.Values[i])*a + b)
-			png.Line(col, i+png.marginx, png.height+png.marginy, i+png.marginx, png.height-v+png.marginy)
+			top[i] = png.height - v + png.marginy
 		}
+		png.columns(col, png.marginx, top, png.height+png.marginy)
+	}
+	for _, d := range png.data {
+		a := float64(d.NMax) / float64(png.height)
+		b := float64(d.NMax) - a*float64(png.height)
+		png.polyline(png.pal.GetColor("marker"), 2, d.Smoothed, a, b)
 	}
 	return nil
 }
 
-// face returns the font face to use. If the role is set to "title" a larger font is used.
-func (png *PNG) face(role myimg.TextRole) {
-	var ttfont *truetype.Font
-	size := 12.
-	dpi := 72.
-	h := font.HintingNone
-
-	if role == myimg.GridRole {
-		f, err := truetype.Parse(gomono.TTF)
-		if err != nil {
-			panic(err)
+// envelope draws a shaded min-max band with the average line on top.
+func (png *PNG) envelope(col color.Color, d data.Data, a, b float64) {
+	band := shade(col, .35)
+	for i := range d.Low {
+		if d.Low[i] == missing {
+			continue
 		}
-		ttfont = f
-	} else {
-		f, err := truetype.Parse(gosmallcapsitalic.TTF)
-		if err != nil {
-			panic(err)
+		lo := png.height - int(float64(d.Low[i])*a+b) + png.marginy
+		hi := png.height - int(float64(d.High[i])*a+b) + png.marginy
+		png.fill(band, i+png.marginx, hi, 1, lo-hi)
+	}
+	png.polyline(col, 1, d.Values, a, b)
+}
+
+// fill fills a rectangle of chart pixels with col, blending it over the
+// image. The rectangle is scaled to device pixels.
+func (png *PNG) fill(col color.Color, x, y, w, h int) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	s := png.scale
+	r := image.Rect(int(float64(x)*s), int(float64(y)*s), int(float64(x+w)*s), int(float64(y+h)*s))
+	png.src.C = col
+	draw.Draw(png.img, r, &png.src, image.Point{}, draw.Over)
+}
+
+// columns fills a column of one pixel wide for each value of top, starting
+// at x, from top to bottom. Opaque columns are written directly into the
+// image row by row, which is much faster than filling each column.
+func (png *PNG) columns(col color.Color, x int, top []int, bottom int) {
+	if _, _, _, a := col.RGBA(); a != 0xffff {
+		for i, t := range top {
+			png.fill(col, x+i, t, 1, bottom-t)
+		}
+		return
+	}
+
+	// Calculate the device pixels of each column.
+	s := png.scale
+	b := png.img.Bounds()
+	x0 := make([]int, len(top)+1)
+	y0 := make([]int, len(top))
+	ymin := b.Max.Y
+	for i := range x0 {
+		x0[i] = clamp(int(float64(x+i)*s), b.Min.X, b.Max.X)
+	}
+	for i, t := range top {
+		y0[i] = clamp(int(float64(t)*s), b.Min.Y, b.Max.Y)
+		if y0[i] < ymin {
+			ymin = y0[i]
 		}
-		ttfont = f
-		size = 15.
 	}
+	ymax := clamp(int(float64(bottom)*s), b.Min.Y, b.Max.Y)
 
-	face := truetype.NewFace(ttfont, &truetype.Options{
-		Size:    size,
-		DPI:     dpi,
-		Hinting: h,
-	})
-	png.gg.SetFontFace(face)
+	c := color.RGBAModel.Convert(col).(color.RGBA)
+	for y := ymin; y < ymax; y++ {
+		row := png.img.Pix[png.img.PixOffset(0, y):]
+		for i := range y0 {
+			if y < y0[i] {
+				continue
+			}
+			for p := x0[i] * 4; p < x0[i+1]*4; p += 4 {
+				row[p], row[p+1], row[p+2], row[p+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// shade returns the color c with opacity a.
+func shade(c color.Color, a float64) color.Color {
+	r, g, b, al := c.RGBA()
+	return color.RGBA64{uint16(float64(r) * a), uint16(float64(g) * a), uint16(float64(b) * a), uint16(float64(al) * a)}
+}
+
+// polyline draws a line through all values scaled using a and b.
+// Missing values interrupt the line.
+func (png *PNG) polyline(col color.Color, width float64, values []int, a, b float64) {
+	if len(values) == 0 {
+		return
+	}
+	pen := false
+	for i, v := range values {
+		if v == missing {
+			pen = false
+			continue
+		}
+		x := float64(i + png.marginx)
+		y := float64(png.height - int(float64(v)*a+b) + png.marginy)
+		if pen {
+			png.gg.LineTo(x, y)
+		} else {
+			png.gg.MoveTo(x, y)
+			pen = true
+		}
+	}
+	png.gg.SetDash()
+	png.gg.SetLineWidth(width * png.scale)
+	png.gg.SetColor(col)
+	png.gg.Stroke()
+}
+
+// face sets the font face to use. If the role is set to "title" a larger font is used.
+func (png *PNG) face(role myimg.TextRole) {
+	png.gg.SetFontFace(png.fontFace(role))
+}
+
+// MeasureText returns the width and height in pixels of a string.
+func (png *PNG) MeasureText(role myimg.TextRole, txt string) (w, h int) {
+	f := png.fontFace(role)
+	unscale := func(v fixed.Int26_6) int {
+		return int(math.Ceil(float64(v) / 64 / png.scale))
+	}
+	return unscale(font.MeasureString(f, txt)), unscale(f.Metrics().Height)
 }
 
 // Text writes a string to the image.
@@ -110,9 +262,13 @@ func (png *PNG) Text(col, align string, role myimg.TextRole, x, y int, txt strin
 	case "end", "right":
 		ax = 1
 	}
+	// Draw in device pixels, the font face is already scaled.
+	png.gg.Push()
+	png.gg.Identity()
 	png.gg.SetColor(png.pal.GetColor(col))
 	png.face(role)
-	png.gg.DrawStringAnchored(txt, float64(x), float64(y), ax, 0)
+	png.gg.DrawStringAnchored(txt, float64(x)*png.scale, float64(y)*png.scale, ax, 0)
+	png.gg.Pop()
 }
 
 // TextID writes a string to the image.
@@ -121,50 +277,56 @@ func (png *PNG) TextID(id, col, align string, role myimg.TextRole, x, y int, txt
 }
 
 // Line draws a line between the points using the color name from the palette.
+// Horizontal and vertical lines are drawn crisp, one pixel wide. Grid lines
+// are thin dashed strokes.
 func (png *PNG) Line(color string, x1, y1, x2, y2 int) {
-	ruler := png.pal.GetColor(color)
+	col := png.pal.GetColor(color)
 	if color == "grid" || color == "grid2" {
-		png.gg.SetDash(1)
-		png.gg.SetLineWidth(.5)
+		png.gg.SetDash(png.scale)
+		png.gg.SetLineWidth(.5 * png.scale)
 		png.gg.DrawLine(float64(x1), float64(y1), float64(x2), float64(y2))
-		png.gg.SetColor(png.pal.GetColor(color))
+		png.gg.SetColor(col)
 		png.gg.Stroke()
-	} else {
-		png.gg.SetLineWidth(1)
+		return
+	}
+	if x1 != x2 && y1 != y2 {
+		png.gg.SetDash()
+		png.gg.SetLineWidth(png.scale)
 		png.gg.DrawLine(float64(x1), float64(y1), float64(x2), float64(y2))
-		png.gg.SetColor(ruler)
+		png.gg.SetColor(col)
 		png.gg.Stroke()
+		return
+	}
+
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
 	}
+	png.fill(col, x1, y1, x2-x1+1, y2-y1+1)
 }
 
 func (png *PNG) rectFill(color string, x1, y1, w, h int) {
-	for i := 0; i < h; i++ {
-		png.Line(color, x1, y1+i, x1+w, y1+i)
-	}
+	png.fill(png.pal.GetColor(color), x1, y1, w, h)
 }
 
 // Legend draws the image specific legend.
-func (png *PNG) Legend(base float64) {
-	x := png.marginx
-	y := png.height + png.marginy + 4
-
-	q := "Min     Max     Avg"
-	png.Text("title2", "right", myimg.GridRole, x+png.width, y+26, q)
-	y += 16
+func (png *PNG) Legend() {
+	l := png.legend
+	if !l.Visible() {
+		return
+	}
+	for c := 0; c < l.Columns; c++ {
+		png.Text("title2", "right", myimg.GridRole, l.Column(c)+l.ColWidth, l.Y+10, l.Header())
+	}
 
 	for i, d := range png.data {
-		png.rectFill(png.pal.GetAxisColorName(i), x, y+16, 12, 12)
-
-		min, max, avg := d.MinMaxAvg()
-		// FIXME use axis formatters for this.
-		mmax := format.SI(max, 1, base, "", "", "")
-		mmin := format.SI(min, 1, base, "", "", "")
-		mavg := format.SI(avg, 1, base, "", "", "")
-		q := fmt.Sprintf("%6s  %6s  %6s", mmin, mmax, mavg)
-		png.Text("title", "left", myimg.GridRole, x+20, y+26, d.Title)
-		png.Text("title", "right", myimg.GridRole, x+png.width, y+26, q)
-		png.Line("grid2", x, y+26+3, x+png.width, y+26+3)
-		y += 16
+		x, y := l.Entry(i)
+		png.rectFill(png.pal.GetAxisColorName(i), x, y, 12, 12)
+		png.Text("title", "left", myimg.GridRole, x+20, y+10, d.Title)
+		png.Text("title", "right", myimg.GridRole, x+l.ColWidth, y+10, l.Stats(i))
+		png.Line("grid2", x, y+10+3, x+l.ColWidth, y+10+3)
 	}
 }
 
@@ -0,0 +1,227 @@
+package png
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+
+	"github.com/tomarus/chart/data"
+	myimg "github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+const (
+	testWidth  = 1440
+	testHeight = 300
+)
+
+func testData(n int, opt *data.Options) data.Collection {
+	c := data.Collection{}
+	for s := 0; s < n; s++ {
+		v := make([]float64, testWidth)
+		for i := range v {
+			v[i] = 100 + 50*math.Sin(float64(i*(s+1))/100)
+		}
+		c = append(c, data.NewData(opt, v))
+	}
+	c.Normalize(testHeight)
+	return c
+}
+
+func testPNG(d data.Collection) *PNG {
+	p, _ := palette.NewPalette("white")
+	l := &myimg.Layout{Width: testWidth + 52, Height: testHeight + 40, Legend: &myimg.Legend{Position: myimg.LegendHidden}}
+	png := New()
+	png.Start(io.Discard, testWidth, testHeight, 48, 20, 0, 86400, p, d, l)
+	return png
+}
+
+// TestCrisp compares the area columns with columns stroked by gg at the
+// pixel centers, which is the crisp edges case. The area pixels changed on
+// purpose: the previous renderer stroked the columns at integer x, so each
+// column was blended over two pixel columns. The grid lines are stroked like
+// the previous renderer did and are expected to be unchanged.
+func TestCrisp(t *testing.T) {
+	d := testData(2, &data.Options{})
+	png := testPNG(d)
+	if err := png.Graph(); err != nil {
+		t.Fatal(err)
+	}
+	png.Line("grid", png.marginx, png.marginy+100, png.marginx+png.width, png.marginy+100)
+	png.Line("grid2", png.marginx+200, png.marginy, png.marginx+200, png.marginy+png.height)
+
+	ref := gg.NewContext(png.canvasw, png.canvash)
+	ref.SetColor(png.pal.GetColor("background"))
+	ref.Clear()
+	ref.SetLineWidth(1)
+	ref.SetLineCapButt()
+	for pt, data := range d {
+		ref.SetColor(png.pal.GetColor(png.pal.GetAxisColorName(pt)))
+		a := float64(data.NMax) / float64(png.height)
+		b := float64(data.NMax) - a*float64(png.height)
+		for i := range data.Values {
+			v := int(float64(data.Values[i])*a + b)
+			x := float64(i+png.marginx) + .5
+			ref.DrawLine(x, float64(png.height+png.marginy), x, float64(png.height-v+png.marginy))
+			ref.Stroke()
+		}
+	}
+
+	// grid lines are 0.5px dashed strokes on top of the areas
+	ref.SetLineCapRound()
+	ref.SetDash(1)
+	ref.SetLineWidth(.5)
+	for _, l := range [][5]int{
+		{0, png.marginx, png.marginy + 100, png.marginx + png.width, png.marginy + 100},
+		{1, png.marginx + 200, png.marginy, png.marginx + 200, png.marginy + png.height},
+	} {
+		ref.SetColor(png.pal.GetColor([]string{"grid", "grid2"}[l[0]]))
+		ref.DrawLine(float64(l[1]), float64(l[2]), float64(l[3]), float64(l[4]))
+		ref.Stroke()
+	}
+
+	im := ref.Image()
+	for y := 0; y < png.canvash; y++ {
+		for x := 0; x < png.canvasw; x++ {
+			if a, b := png.img.At(x, y), im.At(x, y); a != b {
+				t.Fatalf("Pixel %d,%d differs: %v != %v", x, y, a, b)
+			}
+		}
+	}
+}
+
+func benchmarkGraph(b *testing.B, d data.Collection) {
+	for i := 0; i < b.N; i++ {
+		png := testPNG(d)
+		if err := png.Graph(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGraph(b *testing.B) {
+	benchmarkGraph(b, testData(4, &data.Options{}))
+}
+
+func BenchmarkGraphEnvelope(b *testing.B) {
+	d := data.Collection{}
+	for i := 0; i < 4; i++ {
+		v := make([]float64, 10*testWidth)
+		for j := range v {
+			v[j] = 100 + 50*math.Sin(float64(j)/1000) + float64(j%7)
+		}
+		x := data.NewData(&data.Options{Envelope: true}, v)
+		x.Resample(testWidth)
+		d = append(d, x)
+	}
+	d.Normalize(testHeight)
+	benchmarkGraph(b, d)
+}
+
+func BenchmarkGraphScaled(b *testing.B) {
+	d := testData(4, &data.Options{})
+	for i := 0; i < b.N; i++ {
+		png := testPNG(d)
+		png.SetScale(2)
+		if err := png.Graph(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	png := testPNG(testData(4, &data.Options{}))
+	png.Graph()
+	for i := 0; i < b.N; i++ {
+		if err := png.End(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	sizes := map[string]int{}
+	for _, e := range []string{TrueColor, Indexed, GIF, JPEG} {
+		var out bytes.Buffer
+		png := testPNG(testData(2, &data.Options{})).Encoding(e)
+		png.w = &out
+		png.Graph()
+		png.Text("title", "left", myimg.TitleRole, 10, 20, "Antialiased title")
+		if err := png.End(); err != nil {
+			t.Fatal(err)
+		}
+		sizes[e] = out.Len()
+		m, format, err := image.Decode(&out)
+		if err != nil {
+			t.Fatalf("%s: %v", e, err)
+		}
+		if ct := "image/" + format; ct != png.ContentType() {
+			t.Errorf("%s: Expected content type %s, got %s", e, ct, png.ContentType())
+		}
+		if m.Bounds() != png.img.Bounds() {
+			t.Errorf("%s: Expected bounds %v, got %v", e, png.img.Bounds(), m.Bounds())
+		}
+	}
+	if sizes[Indexed] >= sizes[TrueColor] {
+		t.Errorf("Expected indexed png to be smaller than truecolor, got %d and %d bytes", sizes[Indexed], sizes[TrueColor])
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	c := data.Collection{data.NewData(&data.Options{}, []float64{math.NaN(), 0, 0})}
+	c.Normalize(testHeight)
+	if err := testPNG(c).Graph(); err != nil {
+		t.Errorf("Expected missing and zero values to be drawn, got %v", err)
+	}
+}
+
+func TestFont(t *testing.T) {
+	for _, x := range []struct {
+		font myimg.Font
+		err  string
+	}{
+		{myimg.Font{Family: "sans", Weight: "bold"}, ""},
+		{myimg.Font{Family: "smallcaps"}, ""},
+		{myimg.Font{Weight: "bold"}, `font family "smallcaps" has no bold weight`},
+		{myimg.Font{Family: "menlo"}, `unknown font family "menlo"`},
+	} {
+		png := New()
+		err := png.Font(myimg.TitleRole, x.font)
+		if x.err == "" && err != nil || x.err != "" && (err == nil || err.Error() != x.err) {
+			t.Errorf("%+v: Expected error %q, got %v", x.font, x.err, err)
+		}
+		if f := png.fonts[myimg.TitleRole]; err != nil && (f.Family != "smallcaps" || f.Weight != "normal") {
+			t.Errorf("%+v: Expected the font unchanged by errors, got %+v", x.font, f)
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	for _, x := range []struct {
+		delay    time.Duration
+		gif      int
+		num, den uint16
+	}{
+		{250 * time.Millisecond, 25, 250, 1000},
+		{5 * time.Millisecond, 2, 5, 1000},
+		{0, 2, 0, 1000},
+		{100 * time.Second, 10000, 10000, 100},
+		{2 * time.Hour, 65535, 7200, 1},
+		{48 * time.Hour, 65535, 65535, 1},
+	} {
+		if d := gifDelay(x.delay); d != x.gif {
+			t.Errorf("%v: Expected gif delay %d, got %d", x.delay, x.gif, d)
+		}
+		if num, den := apngDelay(x.delay); num != x.num || den != x.den {
+			t.Errorf("%v: Expected png delay %d/%d, got %d/%d", x.delay, x.num, x.den, num, den)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomarus/chart/data"
+)
+
+// response is a response of the Prometheus http api.
+type response struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values []sample          `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// sample is a [timestamp, "value"] pair of a range query result.
+type sample data.Point
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *sample) UnmarshalJSON(b []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(b, &pair); err != nil || len(pair) != 2 {
+		return fmt.Errorf("invalid sample %s", b)
+	}
+	ts, err := strconv.ParseFloat(string(pair[0]), 64)
+	if err != nil {
+		return fmt.Errorf("invalid sample timestamp %s", pair[0])
+	}
+	var v string
+	if err := json.Unmarshal(pair[1], &v); err != nil {
+		return fmt.Errorf("invalid sample value %s", pair[1])
+	}
+	if s.Value, err = strconv.ParseFloat(v, 64); err != nil {
+		return fmt.Errorf("invalid sample value %q", v)
+	}
+	s.Time = time.Unix(0, int64(math.Round(ts*1e3))*int64(time.Millisecond))
+	return nil
+}
+
+// ParseMatrix parses the json response of a range query, which has a result
+// of type matrix.
+func ParseMatrix(r io.Reader) ([]*Series, error) {
+	var resp response
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "success" {
+		if resp.Error == "" {
+			return nil, fmt.Errorf("unexpected status %q", resp.Status)
+		}
+		return nil, fmt.Errorf("%s: %s", resp.ErrorType, resp.Error)
+	}
+	if resp.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unsupported result type %q, expected matrix", resp.Data.ResultType)
+	}
+	res := make([]*Series, len(resp.Data.Result))
+	for i, m := range resp.Data.Result {
+		s := &Series{Labels: m.Metric, Points: make([]data.Point, len(m.Values))}
+		if s.Labels == nil {
+			s.Labels = map[string]string{}
+		}
+		for j, v := range m.Values {
+			s.Points[j] = data.Point(v)
+		}
+		s.sort()
+		res[i] = s
+	}
+	return res, nil
+}
+
+// Client queries the Prometheus http api.
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// NewClient initializes a new client of the Prometheus server at url, e.g.
+// http://localhost:9090.
+func NewClient(url string) *Client {
+	return &Client{url: strings.TrimSuffix(url, "/"), http: http.DefaultClient}
+}
+
+// HTTPClient sets the http client used for requests, e.g. to set a timeout.
+func (c *Client) HTTPClient(h *http.Client) *Client {
+	c.http = h
+	return c
+}
+
+// QueryRange runs the PromQL query from start to end at a resolution of
+// step.
+func (c *Client) QueryRange(query string, start, end time.Time, step time.Duration) ([]*Series, error) {
+	if step < time.Second {
+		step = time.Second
+	}
+	form := url.Values{
+		"query": {query},
+		"start": {strconv.FormatInt(start.Unix(), 10)},
+		"end":   {strconv.FormatInt(end.Unix(), 10)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+	resp, err := c.http.PostForm(c.url+"/api/v1/query_range", form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return nil, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return ParseMatrix(resp.Body)
+}
+
+// Source returns a data source of a query which results in a single series,
+// e.g. an aggregation like sum(rate(http_requests_total[5m])). The query is
+// run when the chart is rendered, at the resolution of the chart.
+func (c *Client) Source(query string) data.DataSource {
+	return data.SourceFunc(func(start, end time.Time, step time.Duration) ([]data.Point, error) {
+		series, err := c.QueryRange(query, start, end, step)
+		if err != nil {
+			return nil, err
+		}
+		switch len(series) {
+		case 0:
+			return nil, nil
+		case 1:
+			return series[0].Points, nil
+		}
+		return nil, fmt.Errorf("query returned %d series, aggregate them to a single series", len(series))
+	})
+}
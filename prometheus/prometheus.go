@@ -0,0 +1,117 @@
+// Package prometheus imports Prometheus metrics as chart data.
+//
+// Series are read from text exposition snapshots, as served on /metrics,
+// using ParseText, or from range query responses of the http api using
+// ParseMatrix or a Client. A Series is a data.DataSource, so it is resampled
+// to the chart width when the chart is rendered:
+//
+//	series, err := prometheus.NewClient("http://prometheus:9090").QueryRange(`rate(node_network_receive_bytes_total[5m])`, start, end, time.Minute)
+//	titles := template.Must(template.New("").Parse("{{.instance}} {{.device}}"))
+//	for _, s := range series {
+//		title, err := s.Title(titles)
+//		c.AddSource(&data.Options{Title: title, Unit: "B/s"}, s)
+//	}
+package prometheus
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/tomarus/chart/data"
+)
+
+// Series is a time series identified by its labels, the metric name is the
+// label __name__.
+type Series struct {
+	Labels map[string]string
+	Points []data.Point // sorted by time
+}
+
+// Name returns the series in the Prometheus notation, e.g.
+// up{instance="localhost:9090",job="prometheus"}.
+func (s *Series) Name() string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	b := []byte(s.Labels["__name__"])
+	if len(keys) > 0 || len(b) == 0 {
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, k...)
+			b = append(b, '=')
+			b = strconv.AppendQuote(b, s.Labels[k])
+		}
+		b = append(b, '}')
+	}
+	return string(b)
+}
+
+// Title returns the title of the series by executing tmpl with the labels,
+// e.g. "{{.instance}} {{.__name__}}". Missing labels are empty. Without
+// template the title is the Name.
+func (s *Series) Title(tmpl *template.Template) (string, error) {
+	if tmpl == nil {
+		return s.Name(), nil
+	}
+	t, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Option("missingkey=zero").Execute(&b, s.Labels); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Query returns the points from start to end. It implements data.DataSource,
+// the resampling is done by the chart.
+func (s *Series) Query(start, end time.Time, step time.Duration) ([]data.Point, error) {
+	i := sort.Search(len(s.Points), func(i int) bool { return !s.Points[i].Time.Before(start) })
+	j := sort.Search(len(s.Points), func(i int) bool { return s.Points[i].Time.After(end) })
+	return s.Points[i:j], nil
+}
+
+// Data returns the series as data set of n values from start to end, titled
+// using the Title of opt.
+func (s *Series) Data(opt *data.Options, start, end time.Time, n int) data.Data {
+	return data.NewData(opt, data.Points(s.Points, start, end, n))
+}
+
+// Merge merges the points of series with the same labels, e.g. of
+// successive snapshots. Series are returned in order of appearance.
+func Merge(lists ...[]*Series) []*Series {
+	var res []*Series
+	byName := map[string]*Series{}
+	for _, l := range lists {
+		for _, s := range l {
+			name := s.Name()
+			m, ok := byName[name]
+			if !ok {
+				m = &Series{Labels: s.Labels}
+				byName[name] = m
+				res = append(res, m)
+			}
+			m.Points = append(m.Points, s.Points...)
+		}
+	}
+	for _, s := range res {
+		s.sort()
+	}
+	return res
+}
+
+// sort sorts the points by time.
+func (s *Series) sort() {
+	sort.SliceStable(s.Points, func(i, j int) bool { return s.Points[i].Time.Before(s.Points[j].Time) })
+}
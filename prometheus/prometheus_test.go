@@ -0,0 +1,168 @@
+package prometheus
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/tomarus/chart/data"
+)
+
+func TestParseText(t *testing.T) {
+	f, err := os.Open("testdata/metrics.prom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	now := time.Unix(1700000120, 0)
+	series, err := ParseText(f, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{
+		`http_requests_total{code="200",method="post"}`,
+		`http_requests_total{code="400",method="post"}`,
+		`msdos_file_access_time_seconds{error="Cannot find file:\n\"FILE.TXT\"",path="C:\\DIR\\FILE.TXT"}`,
+		`metric_without_timestamp_and_labels`,
+		`something_weird{problem="division by zero"}`,
+		`http_request_duration_seconds_bucket{le="0.05"}`,
+		`http_request_duration_seconds_bucket{le="+Inf"}`,
+		`http_request_duration_seconds_sum`,
+	}
+	if len(series) != len(names) {
+		t.Fatalf("Expected %d series, got %d", len(names), len(series))
+	}
+	for i, s := range series {
+		if s.Name() != names[i] {
+			t.Errorf("Expected %s, got %s", names[i], s.Name())
+		}
+	}
+	if p := series[0].Points; len(p) != 2 || p[1].Value != 1093 || p[1].Time.Unix() != 1700000060 {
+		t.Errorf("Expected 2 points, got %v", p)
+	}
+	if p := series[3].Points; len(p) != 1 || p[0].Value != 12.47 || !p[0].Time.Equal(now) {
+		t.Errorf("Expected a point at the snapshot time, got %v", p)
+	}
+	if p := series[4].Points; !math.IsInf(p[0].Value, 1) || p[0].Time.UnixNano() != -3982045*int64(time.Millisecond) {
+		t.Errorf("Expected +Inf before the epoch, got %v", p)
+	}
+
+	for in, msg := range map[string]string{
+		"up 1\n1up 2\n":          `line 2: invalid metric name in "1up 2"`,
+		`up{job="x} 1`:           "line 1: label job: missing closing quote",
+		`up{job=x} 1`:            `line 1: expected =" after label job`,
+		`up{job="x" env="y"} 1`:  "line 1: expected , or } after label job",
+		"up one":                 `line 1: invalid value "one"`,
+		"up 1 now":               `line 1: invalid timestamp "now"`,
+		"up":                     `line 1: expected a value and optional timestamp, got ""`,
+		`up{job="x"} 1 1700 000`: `line 1: expected a value and optional timestamp, got " 1 1700 000"`,
+	} {
+		if _, err := ParseText(strings.NewReader(in), now); err == nil || err.Error() != msg {
+			t.Errorf("%q: Expected %q, got %v", in, msg, err)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, _ := ParseText(strings.NewReader("up{job=\"a\"} 1\nup{job=\"b\"} 1\n"), time.Unix(60, 0))
+	b, _ := ParseText(strings.NewReader("up{job=\"b\"} 0\nup{job=\"a\"} 0\n"), time.Unix(0, 0))
+	m := Merge(a, b)
+	if len(m) != 2 || m[0].Labels["job"] != "a" || len(m[0].Points) != 2 || m[0].Points[0].Value != 0 || m[0].Points[1].Value != 1 {
+		t.Errorf("Unexpected merged series %+v", m)
+	}
+}
+
+func TestParseMatrix(t *testing.T) {
+	f, err := os.Open("testdata/query_range.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	series, err := ParseMatrix(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	p := series[1].Points
+	if len(p) != 3 || p[0].Value != 1 || p[1].Time.UnixNano() != 1700000060500*int64(time.Millisecond) || !math.IsNaN(p[2].Value) {
+		t.Errorf("Unexpected points %v", p)
+	}
+
+	tmpl := template.Must(template.New("").Parse("{{.job}} {{.__name__}}{{.missing}}"))
+	if title, _ := series[0].Title(tmpl); title != "prometheus up" {
+		t.Errorf("Expected title from template, got %q", title)
+	}
+	if title, _ := series[1].Title(nil); title != `up{instance="localhost:9100",job="node"}` {
+		t.Errorf("Expected name as title, got %q", title)
+	}
+
+	start := time.Unix(1700000000, 0)
+	d := series[0].Data(&data.Options{Title: "up"}, start, start.Add(3*time.Minute), 6)
+	if raw := d.Raw(); d.Title != "up" || len(raw) != 6 || raw[0] != 1 || !math.IsNaN(raw[1]) || raw[4] != 0 {
+		t.Errorf("Unexpected data %s %v", d.Title, raw)
+	}
+	if q, _ := series[0].Query(start.Add(time.Second), start.Add(2*time.Minute), time.Minute); len(q) != 2 || q[0].Value != 1 || q[1].Value != 0 {
+		t.Errorf("Expected last 2 points, got %v", q)
+	}
+
+	for in, msg := range map[string]string{
+		`{"status": "error", "errorType": "bad_data", "error": "parse error"}`:                          "bad_data: parse error",
+		`{"status": "success", "data": {"resultType": "vector", "result": []}}`:                         `unsupported result type "vector", expected matrix`,
+		`{"status": "success", "data": {"resultType": "matrix", "result": [{"values": [[1, 2]]}]}}`:     "invalid sample value 2",
+		`{"status": "success", "data": {"resultType": "matrix", "result": [{"values": [[1, "x"]]}]}}`:   `invalid sample value "x"`,
+		`{"status": "success", "data": {"resultType": "matrix", "result": [{"values": [["1", "1"]]}]}}`: `invalid sample timestamp "1"`,
+	} {
+		if _, err := ParseMatrix(strings.NewReader(in)); err == nil || err.Error() != msg {
+			t.Errorf("%s: Expected %q, got %v", in, msg, err)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	var form []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = append(form, r.URL.Path+" "+r.Form.Encode())
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Form.Get("query") {
+		case "up":
+			http.ServeFile(w, r, "testdata/query_range.json")
+		case "sum(up)":
+			w.Write([]byte(`{"status": "success", "data": {"resultType": "matrix", "result": [{"metric": {}, "values": [[1700000000, "2"]]}]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": "error", "errorType": "bad_data", "error": "parse error"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL + "/")
+	start := time.Unix(1700000000, 0)
+	series, err := c.QueryRange("up", start, start.Add(time.Hour), 15*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || form[0] != "/api/v1/query_range end=1700003600&query=up&start=1700000000&step=15" {
+		t.Errorf("Unexpected request %v", form)
+	}
+
+	if p, err := c.Source("sum(up)").Query(start, start.Add(time.Hour), 1500*time.Millisecond); err != nil || len(p) != 1 || p[0].Value != 2 {
+		t.Errorf("Expected a single point, got %v %v", p, err)
+	}
+	if !strings.HasSuffix(form[1], "step=1.5") {
+		t.Errorf("Expected fractional step, got %s", form[1])
+	}
+	if _, err := c.Source("up").Query(start, start.Add(time.Hour), time.Minute); err == nil || err.Error() != "query returned 2 series, aggregate them to a single series" {
+		t.Errorf("Expected error for multiple series, got %v", err)
+	}
+	if _, err := c.QueryRange("up{", start, start.Add(time.Hour), time.Minute); err == nil || err.Error() != "bad_data: parse error" {
+		t.Errorf("Expected api error, got %v", err)
+	}
+}
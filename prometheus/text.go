@@ -0,0 +1,154 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomarus/chart/data"
+)
+
+// ParseText parses a snapshot in the Prometheus text exposition format.
+// Samples without timestamp are at time t, the time of the snapshot.
+// Comments, like # HELP and # TYPE, are ignored. Samples of the same series
+// are merged, so concatenated snapshots with timestamps result in a series
+// of points.
+func ParseText(r io.Reader, t time.Time) ([]*Series, error) {
+	var res []*Series
+	byName := map[string]*Series{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		s, p, err := parseSample(line, t)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		name := s.Name()
+		if m, ok := byName[name]; ok {
+			m.Points = append(m.Points, p)
+			continue
+		}
+		s.Points = []data.Point{p}
+		byName[name] = s
+		res = append(res, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for _, s := range res {
+		s.sort()
+	}
+	return res, nil
+}
+
+// parseSample parses a sample line: name{label="value",...} value [timestamp]
+func parseSample(line string, t time.Time) (*Series, data.Point, error) {
+	s := &Series{Labels: map[string]string{}}
+	i := 0
+	for i < len(line) && isNameChar(line[i], i == 0) {
+		i++
+	}
+	if i == 0 {
+		return nil, data.Point{}, fmt.Errorf("invalid metric name in %q", line)
+	}
+	s.Labels["__name__"] = line[:i]
+	rest := line[i:]
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		if rest, err = parseLabels(rest[1:], s.Labels); err != nil {
+			return nil, data.Point{}, err
+		}
+	}
+
+	f := strings.Fields(rest)
+	if len(f) < 1 || len(f) > 2 {
+		return nil, data.Point{}, fmt.Errorf("expected a value and optional timestamp, got %q", rest)
+	}
+	v, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return nil, data.Point{}, fmt.Errorf("invalid value %q", f[0])
+	}
+	if len(f) == 2 {
+		ms, err := strconv.ParseInt(f[1], 10, 64)
+		if err != nil {
+			return nil, data.Point{}, fmt.Errorf("invalid timestamp %q", f[1])
+		}
+		t = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	return s, data.Point{Time: t, Value: v}, nil
+}
+
+// parseLabels parses the labels after the opening brace into m and returns
+// the rest of the line after the closing brace.
+func parseLabels(in string, m map[string]string) (string, error) {
+	for {
+		in = strings.TrimLeft(in, " \t")
+		if strings.HasPrefix(in, "}") {
+			return in[1:], nil
+		}
+		i := 0
+		for i < len(in) && isNameChar(in[i], i == 0) && in[i] != ':' {
+			i++
+		}
+		if i == 0 {
+			return "", fmt.Errorf("invalid label name in %q", in)
+		}
+		name := in[:i]
+		in = strings.TrimLeft(in[i:], " \t")
+		if !strings.HasPrefix(in, `="`) {
+			return "", fmt.Errorf("expected =\" after label %s", name)
+		}
+		val, rest, err := unquote(in[2:])
+		if err != nil {
+			return "", fmt.Errorf("label %s: %v", name, err)
+		}
+		m[name] = val
+		in = strings.TrimLeft(rest, " \t")
+		if strings.HasPrefix(in, ",") {
+			in = in[1:]
+		} else if !strings.HasPrefix(in, "}") {
+			return "", fmt.Errorf("expected , or } after label %s", name)
+		}
+	}
+}
+
+// unquote returns the label value up to the closing quote, with the
+// escape sequences \\, \" and \n, and the rest after the quote.
+func unquote(in string) (string, string, error) {
+	var b strings.Builder
+	for i := 0; i < len(in); i++ {
+		switch c := in[i]; c {
+		case '"':
+			return b.String(), in[i+1:], nil
+		case '\\':
+			if i++; i == len(in) {
+				break
+			}
+			switch in[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case '\\', '"':
+				b.WriteByte(in[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(in[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("missing closing quote")
+}
+
+// isNameChar returns true if c is valid in a metric name, digits are not
+// valid as first character.
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
@@ -0,0 +1,243 @@
+// Package render serves charts over http, like a Graphite render endpoint.
+//
+// Charts are defined by the query parameters of a GET request, see
+// config.ParseQuery, or by a json or yaml definition POSTed as request body,
+// see config.Parse. The values of each series are read from the Sources of
+// the Handler:
+//
+//	http.Handle("/render/", render.New(sources))
+//
+// The image format is chosen by the extension of the path, e.g.
+// /render/traffic.png, the image of the definition or the Accept header.
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomarus/chart/config"
+)
+
+// formats are the images by extension with their content type. The first
+// format is the default.
+var formats = []struct {
+	ext, image, contentType string
+}{
+	{".svg", "svg", "image/svg+xml"},
+	{".png", "png", "image/png"},
+	{".json", "spec", "application/json"},
+	{".html", "canvas", "text/html; charset=utf-8"},
+	{".pdf", "pdf", "application/pdf"},
+}
+
+// Handler implements http.Handler to render charts.
+type Handler struct {
+	src       config.Sources
+	maxBody   int64
+	maxWidth  int
+	maxHeight int
+	maxSeries int
+}
+
+// New initializes a new handler which reads series values from src.
+// By default definitions are limited to 64KB, images to 4096x4096 pixels
+// and charts to 64 series.
+func New(src config.Sources) *Handler {
+	return &Handler{src: src, maxBody: 64 << 10, maxWidth: 4096, maxHeight: 4096, maxSeries: 64}
+}
+
+// MaxBody sets the maximum size in bytes of POSTed definitions.
+func (h *Handler) MaxBody(n int64) *Handler {
+	h.maxBody = n
+	return h
+}
+
+// MaxSize sets the maximum width and height in pixels of images. Auto sized
+// charts are made smaller to fit, other sizes exceeding it are rejected.
+func (h *Handler) MaxSize(w, hgt int) *Handler {
+	h.maxWidth = w
+	h.maxHeight = hgt
+	return h
+}
+
+// MaxSeries sets the maximum number of series of a chart.
+func (h *Handler) MaxSeries(n int) *Handler {
+	h.maxSeries = n
+	return h
+}
+
+// ServeHTTP renders the chart defined by the request. Invalid definitions
+// result in 400 Bad Request with the error as plain text. Responses have an
+// ETag of the image and are last modified at the end time of the chart, so
+// conditional requests are answered with 304 Not Modified.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var d *config.Definition
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		d, err = config.ParseQuery(r.URL.Query())
+	case http.MethodPost:
+		var b []byte
+		b, err = io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBody))
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("definition exceeds %d bytes", h.maxBody), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d, err = config.Parse(b)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err == nil {
+		err = h.limit(d)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	i := format(path.Ext(r.URL.Path), d.Image, r.Header.Get("Accept"))
+	if i < 0 {
+		http.Error(w, "no acceptable image format", http.StatusNotAcceptable)
+		return
+	}
+	d.Image = formats[i].image
+
+	var buf bytes.Buffer
+	c, err := d.Chart(&buf, h.src)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, ok := err.(*config.Error); ok {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err := c.Render(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	mod := now
+	if end, err := d.End.Unix(now); err == nil && end < now.Unix() {
+		mod = time.Unix(end, 0)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", formats[i].contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Vary", "Accept")
+	http.ServeContent(w, r, "", mod, bytes.NewReader(buf.Bytes()))
+}
+
+// limit checks the size limits of the definition. Auto sized charts are one
+// pixel per value, so they are capped to the maximum size like explicit sizes.
+func (h *Handler) limit(d *config.Definition) error {
+	if len(d.Series) > h.maxSeries {
+		return fmt.Errorf("%d series exceeds %d", len(d.Series), h.maxSeries)
+	}
+	scale := d.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	var width, height int
+	switch d.Size {
+	case "big":
+		width, height = 1440, 360
+	case "small":
+		width, height = 720, 240
+	}
+	if d.Width > 0 {
+		width = d.Width
+	}
+	if d.Height > 0 {
+		height = d.Height
+	}
+	if width == 0 && len(d.Series) > 0 {
+		// The chart takes its width from the first series, see Chart.AddData.
+		// Errors are left to Definition.Chart, which reports the series.
+		if vals, err := h.src.Values(d.Series[0].Source); err == nil {
+			width = len(vals)
+			if w := int(float64(h.maxWidth) / scale); width > w {
+				d.Size, d.Width = "", w
+				width = w
+			}
+			if height == 0 {
+				height = width / 4
+				if m := int(float64(h.maxHeight) / scale); height > m {
+					height = m
+				}
+				d.Height = height
+			}
+		}
+	}
+	sw, sh := int(math.Ceil(float64(width)*scale)), int(math.Ceil(float64(height)*scale))
+	if sw > h.maxWidth || sh > h.maxHeight {
+		return fmt.Errorf("size %dx%d exceeds %dx%d", sw, sh, h.maxWidth, h.maxHeight)
+	}
+	return nil
+}
+
+// format returns the index of the image format by the extension ext, the
+// image of the definition or the Accept header, or -1 if none is acceptable.
+func format(ext, image, accept string) int {
+	for i, f := range formats {
+		if ext != "" && f.ext == ext || ext == "" && image != "" && f.image == image {
+			return i
+		}
+	}
+	if ext != "" {
+		return -1
+	}
+	if accept == "" {
+		return 0
+	}
+
+	type choice struct {
+		typ string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		choices = append(choices, choice{typ, q})
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	for _, c := range choices {
+		if c.q <= 0 {
+			break
+		}
+		for i, f := range formats {
+			ct, _, _ := mime.ParseMediaType(f.contentType)
+			if c.typ == "*/*" || c.typ == ct || c.typ == ct[:strings.IndexByte(ct, '/')]+"/*" {
+				return i
+			}
+		}
+	}
+	return -1
+}
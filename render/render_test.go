@@ -0,0 +1,188 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/tomarus/chart/config"
+	"github.com/tomarus/chart/spec"
+)
+
+var src = config.Values{"rx": {1, 2, 3, 4}, "tx": {4, 3, 2, 1}}
+
+func TestServeHTTP(t *testing.T) {
+	srv := httptest.NewServer(New(src).MaxSize(1000, 500).MaxBody(512))
+	defer srv.Close()
+
+	const q = "?width=300&height=100&start=1700000000&end=1700003600&target=rx&target=tx&series.title=in"
+	for _, x := range []struct {
+		path, accept, contentType string
+	}{
+		{"/render" + q, "", "image/svg+xml"},
+		{"/render.png" + q, "", "image/png"},
+		{"/render" + q, "text/html;q=0.5, image/png", "image/png"},
+		{"/render" + q, "application/*", "application/json"},
+		{"/render" + q + "&image=pdf", "*/*", "application/pdf"},
+		{"/render.svg" + q + "&image=png", "application/json", "image/svg+xml"},
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+x.path, nil)
+		req.Header.Set("Accept", x.accept)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != x.contentType {
+			t.Errorf("%s %s: Expected %s, got %s %s", x.path, x.accept, x.contentType, res.Status, res.Header.Get("Content-Type"))
+		}
+		if res.Header.Get("ETag") == "" || res.Header.Get("Last-Modified") != "Tue, 14 Nov 2023 23:13:20 GMT" {
+			t.Errorf("Expected ETag and Last-Modified, got %v", res.Header)
+		}
+	}
+
+	res, err := http.Post(srv.URL+"/render.json", "application/yaml", strings.NewReader("width: 300\nheight: 100\nseries: [{source: rx, title: in}]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc spec.Chart
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if doc.Area.Width != 300 || len(doc.Series) != 1 || doc.Series[0].Title != "in" {
+		t.Errorf("Unexpected chart %d %+v", doc.Area.Width, doc.Series)
+	}
+
+	// conditional requests
+	res, _ = http.Get(srv.URL + "/render" + q)
+	res.Body.Close()
+	for name, val := range map[string]string{"If-None-Match": res.Header.Get("ETag"), "If-Modified-Since": res.Header.Get("Last-Modified")} {
+		req, _ := http.NewRequest("GET", srv.URL+"/render"+q, nil)
+		req.Header.Set(name, val)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotModified {
+			t.Errorf("%s: Expected 304, got %s", name, res.Status)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	h := New(src).MaxSize(1000, 500).MaxBody(64).MaxSeries(2)
+	for _, x := range []struct {
+		method, path, body string
+		status             int
+		msg                string
+	}{
+		{"GET", "/?target=rx&width=abc", "", 400, `width: expected an integer, got "abc"`},
+		{"GET", "/?target=rx&colour=red", "", 400, "colour: unknown field"},
+		{"GET", "/?target=rx&axes.y=si", "", 400, "axes.y: missing field name, e.g. axes.y.format"},
+		{"GET", "/?target=rx&target=eth0", "", 400, `series[1].source: unknown source "eth0"`},
+		{"GET", "/?target=rx&series.type=area&series.type=bars", "", 400, `series[1].source: missing source`},
+		{"GET", "/?target=rx&width=2000", "", 400, "size 2000x0 exceeds 1000x500"},
+		{"GET", "/?target=rx&target=rx&target=tx", "", 400, "3 series exceeds 2"},
+		{"GET", "/?target=rx&size=big", "", 400, "size 1440x360 exceeds 1000x500"},
+		{"GET", "/?target=rx&size=small&scale=2", "", 400, "size 1440x480 exceeds 1000x500"},
+		{"GET", "/?target=rx&size=small&height=600", "", 400, "size 720x600 exceeds 1000x500"},
+		{"GET", "/chart.gif?target=rx", "", 406, "no acceptable image format"},
+		{"POST", "/", `{"series": [{"source": "rx", "smooth": {"method": "x"}}]}`, 400, `series[0].smooth.method: unknown smoothing method "x"`},
+		{"POST", "/", strings.Repeat(" ", 65), 413, "definition exceeds 64 bytes"},
+		{"DELETE", "/", "", 405, "method not allowed"},
+	} {
+		req := httptest.NewRequest(x.method, x.path, strings.NewReader(x.body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != x.status || strings.TrimSpace(rec.Body.String()) != x.msg {
+			t.Errorf("%s %s: Expected %d %q, got %d %q", x.method, x.path, x.status, x.msg, rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/", iotest.ErrReader(errors.New("connection reset")))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || strings.TrimSpace(rec.Body.String()) != "connection reset" {
+		t.Errorf("Expected 400 for read error, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/?target=rx", nil)
+	req.Header.Set("Accept", "text/plain, image/*;q=0")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected 406, got %d", rec.Code)
+	}
+}
+
+func TestAutoSize(t *testing.T) {
+	src := config.Values{"short": make([]float64, 400), "long": make([]float64, 20000)}
+	h := New(src).MaxSize(1000, 500)
+	for _, x := range []struct {
+		query         string
+		width, height int
+	}{
+		{"target=short", 400, 100},
+		{"target=long", 1000, 250},
+		{"target=long&size=auto&scale=2", 500, 125},
+		{"target=long&height=400", 1000, 400},
+	} {
+		req := httptest.NewRequest("GET", "/x.json?"+x.query, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var doc spec.Chart
+		if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+			t.Fatalf("%s: %v", x.query, err)
+		}
+		if doc.Area.Width != x.width || doc.Area.Height != x.height {
+			t.Errorf("%s: Expected %dx%d, got %dx%d", x.query, x.width, x.height, doc.Area.Width, doc.Area.Height)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	req := httptest.NewRequest("GET", "/x.svg?width=300&height=100&title=<script>alert(1)</script>&target=rx&series.title=a%26b&series.unit=<b>&titleFont.family=x%3B}%3C/style%3E", nil)
+	rec := httptest.NewRecorder()
+	New(src).ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || strings.Contains(body, "<script>alert") || !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("Expected escaped title, got %d %s", rec.Code, body)
+	}
+	if strings.Contains(body, "<b>") || strings.Contains(body, ">a&b<") || strings.Count(body, "</style>") != 1 || !strings.Contains(body, "font-family: xstyle;") {
+		t.Errorf("Expected escaped series title, unit and font family, got %s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/x.html?width=300&height=100&title=</script><script>alert(1)</script>&target=rx&titleFont.family=x%27;alert(1)", nil)
+	rec = httptest.NewRecorder()
+	New(src).ServeHTTP(rec, req)
+	body = rec.Body.String()
+	if rec.Code != http.StatusOK || strings.Count(body, "</script>") != 1 || strings.Contains(body, "x'") {
+		t.Errorf("Expected escaped html, got %d %s", rec.Code, body)
+	}
+}
+
+func TestETag(t *testing.T) {
+	h := New(src)
+	for _, ext := range []string{".svg", ".png", ".json", ".html", ".pdf"} {
+		etag := ""
+		for i := 0; i < 10; i++ {
+			req := httptest.NewRequest("GET", "/x"+ext+"?width=300&height=100&title=t&target=rx&target=tx&start=1700000000&end=1700086400", nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s: Expected 200, got %d %s", ext, rec.Code, rec.Body.String())
+			}
+			if i > 0 && rec.Header().Get("ETag") != etag {
+				t.Errorf("%s: Expected the same ETag, got %s and %s", ext, etag, rec.Header().Get("ETag"))
+				break
+			}
+			etag = rec.Header().Get("ETag")
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package spec
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/tomarus/chart/data"
+)
+
+// Chart is the json document of a chart.
+type Chart struct {
+	Version     int              `json:"version"`
+	Width       int              `json:"width"`  // image width
+	Height      int              `json:"height"` // image height
+	Area        Rect             `json:"area"`   // chart area
+	Start       int64            `json:"start"`  // epoch of the left of the chart area
+	End         int64            `json:"end"`    // epoch of the right of the chart area
+	Title       *Text            `json:"title,omitempty"`
+	Colors      map[string]Color `json:"colors"` // palette colors by name
+	Fonts       map[string]Font  `json:"fonts"`  // "title" and "grid" fonts
+	XAxis       Axis             `json:"xaxis"`
+	YAxis       Axis             `json:"yaxis"` // labels of the first series, see Series.Scale
+	Series      []Series         `json:"series"`
+	Legend      *Legend          `json:"legend,omitempty"`
+	Annotations []Annotation     `json:"annotations,omitempty"` // e.g. percentile lines
+}
+
+// Rect is a rectangle in pixels of the image.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Color is a palette color.
+type Color struct {
+	Hex   string  `json:"hex"` // #rrggbb
+	Alpha float64 `json:"alpha"`
+}
+
+// Font is the font of text.
+type Font struct {
+	Family string  `json:"family"`
+	Size   float64 `json:"size"` // in pixels
+	Weight string  `json:"weight"`
+}
+
+// Axis contains the labels and grid lines of an axis.
+type Axis struct {
+	Ticks []Tick `json:"ticks"`
+	Grid  []Grid `json:"grid"`
+}
+
+// Tick is a label of an axis. Values of the X axis are epochs.
+type Tick struct {
+	Pos   int     `json:"pos"` // position from the left or bottom of the chart area
+	Value float64 `json:"value"`
+	Label string  `json:"label"`
+}
+
+// Grid is a grid line of an axis.
+type Grid struct {
+	Pos   int  `json:"pos"`   // position from the left or bottom of the chart area
+	Major bool `json:"major"` // drawn using the grid color, minor lines use grid2
+}
+
+// Series is a dataset. Pixel values are heights from the bottom of the
+// chart area, scaled to the max of the series. Multiply by Height divided by
+// the height of the chart area to draw series together, like the first
+// series.
+type Series struct {
+	Title      string      `json:"title"`
+	Unit       string      `json:"unit,omitempty"`
+	Type       string      `json:"type"`   // "area" or "line"
+	Color      string      `json:"color"`  // palette color name
+	Max        float64     `json:"max"`    // max raw value
+	Height     int         `json:"height"` // height of the max in the scale of all series
+	Scale      []string    `json:"scale"`  // Y axis labels in the scale of this series
+	Values     Pixels      `json:"values"`
+	Raw        Values      `json:"raw"`                // values of each pixel
+	Low        Pixels      `json:"low,omitempty"`      // envelope minimum
+	High       Pixels      `json:"high,omitempty"`     // envelope maximum
+	Smoothed   Pixels      `json:"smoothed,omitempty"` // smoothed overlay line
+	Percentile *Percentile `json:"percentile,omitempty"`
+	Legend     *Entry      `json:"legend,omitempty"`
+}
+
+// Percentile is the percentile line of a series.
+type Percentile struct {
+	Percentile float64 `json:"percentile"`
+	Value      Value   `json:"value"`
+}
+
+// Legend is the layout of the legend.
+type Legend struct {
+	Position string `json:"position"` // "bottom", "top" or "right"
+	Rect
+	Columns     int `json:"columns"`
+	Rows        int `json:"rows"`
+	ColumnWidth int `json:"columnWidth"`
+}
+
+// Entry is the legend entry of a series.
+type Entry struct {
+	X     int    `json:"x"` // top left of the color swatch
+	Y     int    `json:"y"`
+	Stats []Stat `json:"stats"`
+}
+
+// Stat is a legend statistic.
+type Stat struct {
+	Name  string `json:"name"` // e.g. "min" or "p95"
+	Value Value  `json:"value"`
+	Label string `json:"label"` // formatted value
+}
+
+// Annotation is a line or text drawn on the chart which is not part of the
+// axes or the legend.
+type Annotation struct {
+	Line *Line `json:"line,omitempty"`
+	Text *Text `json:"text,omitempty"`
+}
+
+// Line is a line in pixels of the image.
+type Line struct {
+	Color string `json:"color"`
+	X1    int    `json:"x1"`
+	Y1    int    `json:"y1"`
+	X2    int    `json:"x2"`
+	Y2    int    `json:"y2"`
+}
+
+// Text is text in pixels of the image, Y is the baseline.
+type Text struct {
+	Text  string `json:"text"`
+	Color string `json:"color"`
+	Align string `json:"align"` // "left", "middle" or "right"
+	Font  string `json:"font"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+}
+
+// Pixels are pixel values, missing values are written as null.
+type Pixels []int
+
+// MarshalJSON implements json.Marshaler.
+func (p Pixels) MarshalJSON() ([]byte, error) {
+	b := []byte{'['}
+	for i, v := range p {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if v == data.Missing {
+			b = append(b, "null"...)
+			continue
+		}
+		b = strconv.AppendInt(b, int64(v), 10)
+	}
+	return append(b, ']'), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Pixels) UnmarshalJSON(b []byte) error {
+	var v []*int
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*p = make(Pixels, len(v))
+	for i := range v {
+		(*p)[i] = data.Missing
+		if v[i] != nil {
+			(*p)[i] = *v[i]
+		}
+	}
+	return nil
+}
+
+// Values are values, NaN and infinite values are written as null.
+type Values []float64
+
+// MarshalJSON implements json.Marshaler.
+func (vs Values) MarshalJSON() ([]byte, error) {
+	b := []byte{'['}
+	for i, v := range vs {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = Value(v).append(b)
+	}
+	return append(b, ']'), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (vs *Values) UnmarshalJSON(b []byte) error {
+	var v []Value
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*vs = make(Values, len(v))
+	for i := range v {
+		(*vs)[i] = float64(v[i])
+	}
+	return nil
+}
+
+// Value is a value, NaN and infinite values are written as null.
+type Value float64
+
+func (v Value) append(b []byte) []byte {
+	if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+		return append(b, "null"...)
+	}
+	return strconv.AppendFloat(b, float64(v), 'g', -1, 64)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (v Value) MarshalJSON() ([]byte, error) {
+	return v.append(nil), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, null is NaN.
+func (v *Value) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*v = Value(math.NaN())
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(b), 64)
+	*v = Value(f)
+	return err
+}
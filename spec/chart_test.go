@@ -0,0 +1,33 @@
+package spec
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/tomarus/chart/data"
+)
+
+func TestValues(t *testing.T) {
+	in := Series{Values: Pixels{1, data.Missing, 3}, Raw: Values{1.5, math.NaN(), math.Inf(1)}}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	json.Unmarshal(b, &out)
+	if v := out["values"].([]interface{}); v[1] != nil || v[2] != 3. {
+		t.Errorf("Expected missing pixel as null, got %v", v)
+	}
+	if v := out["raw"].([]interface{}); v[0] != 1.5 || v[1] != nil || v[2] != nil {
+		t.Errorf("Expected NaN and Inf as null, got %v", v)
+	}
+
+	var s Series
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Values[1] != data.Missing || s.Values[2] != 3 || s.Raw[0] != 1.5 || !math.IsNaN(s.Raw[1]) {
+		t.Errorf("Expected values to round trip, got %v %v", s.Values, s.Raw)
+	}
+}
@@ -0,0 +1,185 @@
+// Package spec writes charts as a json document, so other renderers, like a
+// frontend, can draw them without implementing the axis, tick, legend and
+// palette logic.
+//
+// The document is a Chart. All positions are in pixels of the image, except
+// the tick and grid positions and the series values which are relative to
+// the chart area, with y up. Missing values are null.
+//
+// The document is versioned. Within a version fields are only added, an
+// incompatible change increments Version.
+package spec
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+// Version is the version of the document written by Spec.
+const Version = 1
+
+// Spec implements the chart interface to write json chart specifications.
+type Spec struct {
+	w      io.Writer
+	doc    Chart
+	data   data.Collection
+	max    float64
+	pal    *palette.Palette
+	legend *image.Legend
+	image.TextMetrics
+}
+
+// New initializes a new chart specification writer.
+func New() *Spec {
+	return &Spec{
+		TextMetrics: image.NewTextMetrics(),
+	}
+}
+
+// Start initializes a new document.
+func (s *Spec) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *image.Layout) {
+	s.w = wr
+	s.data = d
+	s.pal = p
+	s.legend = l.Legend
+	s.max = 0
+	if len(d) > 0 {
+		s.max = d[0].Max
+	}
+	s.doc = Chart{
+		Version: Version,
+		Width:   l.Width,
+		Height:  l.Height,
+		Area:    Rect{X: mx, Y: my, Width: w, Height: h},
+		Start:   start,
+		End:     end,
+		Colors:  map[string]Color{},
+		Fonts:   map[string]Font{},
+		XAxis:   Axis{Ticks: []Tick{}, Grid: []Grid{}},
+		YAxis:   Axis{Ticks: []Tick{}, Grid: []Grid{}},
+		Series:  []Series{},
+	}
+	for _, n := range []string{"background", "grid", "grid2", "title", "title2", "border", "marker", "select"} {
+		s.color(n)
+	}
+	for role, name := range map[image.TextRole]string{image.TitleRole: "title", image.GridRole: "grid"} {
+		f := s.Fonts[role]
+		s.doc.Fonts[name] = Font{Family: f.Family, Size: f.Size, Weight: f.Weight}
+	}
+}
+
+// color adds the palette color name to the document.
+func (s *Spec) color(name string) {
+	s.doc.Colors[name] = Color{Hex: s.pal.GetHexColor(name), Alpha: s.pal.GetAlpha(name)}
+}
+
+// Graph adds all datasets to the document.
+func (s *Spec) Graph() error {
+	for i := range s.data {
+		d := &s.data[i]
+		col := s.pal.GetAxisColorName(i)
+		s.color(col)
+		sr := Series{
+			Title:    d.Title,
+			Unit:     d.Unit,
+			Type:     d.Type,
+			Color:    col,
+			Max:      d.Max,
+			Height:   d.NMax,
+			Scale:    d.Scale,
+			Values:   Pixels(d.Values),
+			Raw:      Values(d.Raw()),
+			Low:      Pixels(d.Low),
+			High:     Pixels(d.High),
+			Smoothed: Pixels(d.Smoothed),
+		}
+		if p := d.PercentileLine(); p > 0 {
+			sr.Percentile = &Percentile{Percentile: p, Value: Value(d.Percentile(p))}
+		}
+		s.doc.Series = append(s.doc.Series, sr)
+	}
+	return nil
+}
+
+// Text adds the title or an annotation to the document.
+func (s *Spec) Text(color, align string, role image.TextRole, x, y int, txt string) {
+	t := Text{Text: txt, Color: color, Align: align, Font: "grid", X: x, Y: y}
+	if role == image.TitleRole {
+		t.Font = "title"
+		if s.doc.Title == nil {
+			s.doc.Title = &t
+			return
+		}
+	}
+	s.doc.Annotations = append(s.doc.Annotations, Annotation{Text: &t})
+}
+
+// TextID adds the axis labels as ticks to the document, other text is added
+// as annotation.
+func (s *Spec) TextID(id, color, align string, role image.TextRole, x, y int, txt string) {
+	a := s.doc.Area
+	switch id {
+	case "grid":
+		pos := x - a.X
+		v := float64(s.doc.Start) + float64(pos)*float64(s.doc.End-s.doc.Start)/float64(a.Width)
+		s.doc.XAxis.Ticks = append(s.doc.XAxis.Ticks, Tick{Pos: pos, Value: v, Label: txt})
+	case "ygrid":
+		_, th := s.MeasureText(role, txt)
+		pos := a.Height - (y - th/4 - a.Y)
+		v := s.max * float64(pos) / float64(a.Height)
+		s.doc.YAxis.Ticks = append(s.doc.YAxis.Ticks, Tick{Pos: pos, Value: v, Label: txt})
+	default:
+		s.Text(color, align, role, x, y, txt)
+	}
+}
+
+// Line adds the grid lines to the axes, other lines are added as annotation.
+func (s *Spec) Line(color string, x1, y1, x2, y2 int) {
+	a := s.doc.Area
+	if color == "grid" || color == "grid2" {
+		g := Grid{Major: color == "grid"}
+		switch {
+		case x1 == x2:
+			g.Pos = x1 - a.X
+			s.doc.XAxis.Grid = append(s.doc.XAxis.Grid, g)
+			return
+		case y1 == y2:
+			g.Pos = a.Height - (y1 - a.Y)
+			s.doc.YAxis.Grid = append(s.doc.YAxis.Grid, g)
+			return
+		}
+	}
+	s.color(color)
+	s.doc.Annotations = append(s.doc.Annotations, Annotation{Line: &Line{Color: color, X1: x1, Y1: y1, X2: x2, Y2: y2}})
+}
+
+// Legend adds the legend layout and the statistics of each dataset.
+func (s *Spec) Legend() {
+	l := s.legend
+	if !l.Visible() {
+		return
+	}
+	s.doc.Legend = &Legend{Position: l.Position, Rect: Rect{X: l.X, Y: l.Y, Width: l.Width, Height: l.Height},
+		Columns: l.Columns, Rows: l.Rows, ColumnWidth: l.ColWidth}
+	for i := range s.doc.Series {
+		sr := &s.doc.Series[i]
+		x, y := l.Entry(i)
+		sr.Legend = &Entry{X: x, Y: y}
+		for j, name := range l.Statistics() {
+			v, _ := s.data[i].Stat(name)
+			sr.Legend.Stats = append(sr.Legend.Stats, Stat{Name: name, Value: Value(v), Label: l.Cells(i)[j]})
+		}
+	}
+}
+
+// Border is part of the chart area.
+func (s *Spec) Border(x, y, w, h int) {}
+
+// End writes the document to the output writer.
+func (s *Spec) End() error {
+	return json.NewEncoder(s.w).Encode(&s.doc)
+}
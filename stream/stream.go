@@ -0,0 +1,131 @@
+// Package stream serves chart samples as Server-Sent Events.
+// It is used to update svg charts created with svg.New().Stream(url) live.
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sample is a single set of values at a point in time. Values are keyed
+// by the title of the dataset they belong to.
+type Sample struct {
+	ID     uint64             `json:"-"`
+	Time   int64              `json:"t"`
+	Values map[string]float64 `json:"v"`
+}
+
+// Ring is a fixed size ring buffer of samples. It implements http.Handler
+// to serve the samples as an event stream. Clients reconnecting with a
+// Last-Event-ID header receive the samples they missed, as long as these
+// are still available in the buffer.
+type Ring struct {
+	mu   sync.Mutex
+	buf  []Sample
+	pos  int
+	seq  uint64
+	wake chan struct{}
+}
+
+// NewRing creates a new Ring which keeps the last size samples.
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{buf: make([]Sample, 0, size), wake: make(chan struct{})}
+}
+
+// Push adds a new sample to the ring buffer and wakes up all listeners.
+// NaN and infinite values are not sent, the svg treats these as missing values.
+func (r *Ring) Push(t time.Time, values map[string]float64) {
+	v := make(map[string]float64, len(values))
+	for k, f := range values {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			continue
+		}
+		v[k] = f
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	s := Sample{ID: r.seq, Time: t.Unix(), Values: v}
+	if len(r.buf) < cap(r.buf) {
+		r.buf = append(r.buf, s)
+	} else {
+		r.buf[r.pos] = s
+		r.pos = (r.pos + 1) % len(r.buf)
+	}
+	close(r.wake)
+	r.wake = make(chan struct{})
+}
+
+// Since returns all buffered samples with an ID larger than id, oldest first.
+// The returned channel is closed when a new sample is pushed.
+func (r *Ring) Since(id uint64) ([]Sample, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := []Sample{}
+	for i := range r.buf {
+		s := r.buf[(r.pos+i)%len(r.buf)]
+		if s.ID > id {
+			res = append(res, s)
+		}
+	}
+	return res, r.wake
+}
+
+// last returns the ID of the most recent sample.
+func (r *Ring) last() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.seq
+}
+
+// ServeHTTP streams new samples to the client until the request is cancelled.
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	id := r.last()
+	if lid := req.Header.Get("Last-Event-ID"); lid != "" {
+		if n, err := strconv.ParseUint(lid, 10, 64); err == nil && n <= id {
+			id = n
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		samples, wake := r.Since(id)
+		for _, s := range samples {
+			js, err := json.Marshal(s)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", s.ID, js); err != nil {
+				return
+			}
+			id = s.ID
+		}
+		flusher.Flush()
+
+		select {
+		case <-wake:
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
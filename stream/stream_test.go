@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(3)
+	for i := 0; i < 5; i++ {
+		r.Push(time.Unix(int64(i), 0), map[string]float64{"a": float64(i), "b": math.NaN()})
+	}
+
+	s, _ := r.Since(0)
+	if len(s) != 3 {
+		t.Fatalf("Expected 3 samples, got %d", len(s))
+	}
+	for i, x := range s {
+		if x.ID != uint64(i+3) || x.Time != int64(i+2) || x.Values["a"] != float64(i+2) {
+			t.Errorf("Unexpected sample %d: %#v", i, x)
+		}
+		if _, ok := x.Values["b"]; ok {
+			t.Errorf("NaN values should be dropped")
+		}
+	}
+
+	s, _ = r.Since(4)
+	if len(s) != 1 || s[0].ID != 5 {
+		t.Errorf("Expected only sample 5, got %#v", s)
+	}
+}
+
+func TestRingWake(t *testing.T) {
+	r := NewRing(1)
+	_, wake := r.Since(0)
+	r.Push(time.Now(), nil)
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("Push should close the wake channel")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRing(10)
+	r.Push(time.Unix(1, 0), map[string]float64{"a": 1})
+	r.Push(time.Unix(2, 0), map[string]float64{"a": 2})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+
+	go r.Push(time.Unix(3, 0), map[string]float64{"a": 3})
+
+	expect := []string{"id: 2", `data: {"t":2,"v":{"a":2}}`, "", "id: 3", `data: {"t":3,"v":{"a":3}}`}
+	rd := bufio.NewReader(res.Body)
+	for _, e := range expect {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimRight(line, "\n") != e {
+			t.Errorf("Expected %q got %q", e, line)
+		}
+	}
+}
@@ -1,17 +1,21 @@
 package svg
 
-const js = `
+import "github.com/tomarus/chart/image"
+
+const js = image.Script + `
 let active, mkx, mkx2, mky, mky2, mks, mkt, loc, selx, sely, seltxt="", mavtxt="", mav=0, selmode=0
-let dopt = {year: "numeric", month: "2-digit", day: "2-digit", hour: "2-digit", minute: "2-digit", hour12: false}
 window.onload = init
 document.addEventListener('load', init)
 function id(n) { return 'path'+(n+1) }
 function idb(n) { return 'path'+(n+1)+'_b' }
+function button(n) { return document.getElementById(idb(n)) || {style: {}} }
 function init() {
 	data.forEach((d, i) => {
-		document.getElementById(idb(i)).onclick = () => { click(i); selmode = 0 }
+		button(i).onclick = () => { click(i); selmode = 0 }
 	})
 	document.getElementById('mabut').onclick = () => { maclick(); selmode = 0 }
+	exportbut('csvbut', csv)
+	exportbut('jsonbut', json)
 	render(0)
 	mkx = document.getElementById('markerx')
 	mky = document.getElementById('markery')
@@ -20,6 +24,7 @@ function init() {
 	mkt = document.getElementById('markertext')
 	mks = document.getElementById('markersel')
 	handlemouse()
+	live()
 }
 function handlemouse() {
 	let svg = document.querySelector('svg')
@@ -103,25 +108,13 @@ function marker(s, x, y) {
 	if (selmode) {
 		let v2 = data[active||0].fmax / h * dy
 		let t = (end-start) / w * dx / 1000
-		seltxt += ' Len: ' + fmtime(t) + ' Delta-Y: ' + fmt(Math.abs(v2))
+		seltxt += ' Len: ' + fmtime(t) + ' Delta-Y: ' + fmtu(Math.abs(v2), data[active||0])
 	} else {
-		seltxt += ' Y:' + fmt(v)
+		seltxt += ' Y:' + fmtu(v, data[active||0])
 	}
 }
 function status() {
-	mkt.innerHTML = seltxt + (mavtxt !== "" ? " " + mavtxt : "")
-}
-function fmt(b) {
-	if (b < 1000000) return b.toFixed()
-	let sizes = ['', 'K', 'M', 'G', 'T', 'P']
-	let i = Math.floor(Math.log(b) / Math.log(1000))
-	return parseFloat((b / Math.pow(1000, i))).toFixed(3) + '' + sizes[i]
-}
-function fmtime(t) {
-	let d = Math.floor(t/86400)
-	let h = Math.floor(t/3600)%24
-	let m = Math.floor(t/60)%60
-	return (d>0?d+'d ':'')+(h>0?h+'h ':'')+(m>0?m+'m':'')
+	mkt.textContent = seltxt + (mavtxt !== "" ? " " + mavtxt : "")
 }
 function click(n) {
 	if (active === n) {
@@ -137,7 +130,7 @@ function click(n) {
 }
 function style(n, v, o) {
 	document.getElementById(id(n)).style.visibility = v
-	document.getElementById(idb(n)).style.fillOpacity = o
+	button(n).style.fillOpacity = o
 }
 function styles(v, o) {
 	data.forEach((d, i) => {
@@ -147,7 +140,7 @@ function styles(v, o) {
 function scale(n) {
 	let c = document.getElementById('ygrid').children
 	for (i=0; i<c.length; i++) {
-		c[i].children[0].innerHTML = data[n].scale[i]
+		c[i].children[0].textContent = data[n].scale[i]
 	}
 }
 function norm(v, max, fmax) {
@@ -158,7 +151,12 @@ function norm(v, max, fmax) {
 function render(n) {
 	scale(n)
 	data.forEach((d, i) => {
-		window[data[i].type](i, h, i === n ? h : data[i].max)
+		if (d.high) {
+			envelope(i, h, i === n ? h : data[i].max)
+		} else {
+			window[data[i].type](i, h, i === n ? h : data[i].max)
+		}
+		smoothed(i, h, i === n ? h : data[i].max)
 	})
 	ma(n)
 }
@@ -171,6 +169,7 @@ function line(n, max, fmax) {
 }
 function graph(t, p, n, max, fmax) {
 	for (let i=0; i<Math.min(w, data[n].values.length); i++) {
+		if (data[n].values[i] === missing) continue
 		let v = norm(data[n].values[i], max, fmax)
 		// if (t === 'line') {
 		// 	p += 'L'+i+','+(h-v)
@@ -178,72 +177,124 @@ function graph(t, p, n, max, fmax) {
 			p += 'M'+i+','+(h-v)+'v'+v
 		// }
 	}
-	document.getElementById(id(n)).firstElementChild.setAttribute('d', p)
+	document.getElementById(id(n)).children[1].setAttribute('d', p)
 }
-function valof(n, pos, off, max) {
-	if (pos+off<0 || pos+off>=max) {
-		return -1
+function envelope(n, max, fmax) {
+	let p = ''
+	for (let i=0; i<Math.min(w, data[n].low.length); i++) {
+		if (data[n].low[i] === missing) continue
+		let lo = norm(data[n].low[i], max, fmax)
+		let hi = norm(data[n].high[i], max, fmax)
+		p += 'M'+i+','+(h-lo)+'V'+(h-hi)
 	}
-	return data[n].values[pos+off]
-}
-function findma(n, pos, size) {
-	const minValue = 1
-	if (size == w) {
-		let v = 0, tw = 0
-		for (let j=0; j<size; j++) {
-			if (data[n].values[j]>=minValue) {
-				v += data[n].values[j]
-				tw++
-			}
-		}
-		return v/tw
-	}
-	let v = data[n].values[pos]
-	let dx = data[n].values.length
-	let totw = 0
-	for (let j=0; j<size; j++) {
-		let wx = size-j-1
-		let q = valof(n, pos, j+1, dx)
-		if (q>=minValue) {
-			totw += wx
-			v += q*wx
-		}
-		q = valof(n, pos, -j-1, dx)
-		if (q>=minValue) {
-			totw += wx
-			v += q*wx
+	let c = document.getElementById(id(n)).children
+	c[0].setAttribute('d', p || 'M0,0')
+	c[1].setAttribute('d', path(data[n].values, max, fmax))
+}
+function path(values, max, fmax) {
+	let p = '', pen = false
+	for (let i=0; i<Math.min(w, values.length); i++) {
+		if (values[i] === missing) {
+			pen = false
+			continue
 		}
+		let v = norm(values[i], max, fmax)
+		p += (pen ? 'L' : 'M')+i+','+(h-v)
+		pen = true
 	}
-	v /= totw+1
-	return v
+	return p || 'M0,0'
+}
+function smoothed(n, max, fmax) {
+	document.getElementById(id(n)).children[2].setAttribute('d', path(data[n].smoothed || [], max, fmax))
 }
 function ma(n) {
 	if (mav===0) {
 		document.getElementById('ma').firstElementChild.setAttribute('d', 'M0,0')
 		return
 	}
-	let smooth = 1<<mav
-	if (smooth>w) {
-		smooth = w
-	}
-	let v0 = norm(findma(n, 0, smooth), h, h)
-	let p = 'M0,'+(h-v0)
-	for (let i=0; i<Math.min(w, data[n].values.length); i++) {
-		let v = norm(findma(n, i, smooth), h, h)
-		p += 'L'+i+','+(h-v)
-	}
+	let p = path(topixels(smooth(raw[n], smoothing(n)), data[n].fmax), h, h)
 	document.getElementById('ma').firstElementChild.setAttribute('d', p)
 }
-function maclick() {
-	if (mav === w || 1<<mav > w) {
-		mav = -1
+function exportbut(n, f) {
+	let e = document.getElementById(n)
+	e.addEventListener('mousedown', (evt) => evt.stopPropagation())
+	e.onclick = f
+}
+function exportrange() {
+	let from = 0, to = w
+	if (selmode == 2) {
+		let x2 = parseFloat(mkx2.getAttribute('x1'))
+		from = Math.max(0, Math.floor(Math.min(selx, x2) - mx))
+		to = Math.min(w, Math.ceil(Math.max(selx, x2) - mx) + 1)
 	}
-	mav++
-	ma(active||0)
-	mavtxt = ""
-	if (mav>0) {
-		mavtxt = "WMA:" + (1<<mav > w ? "all" : 1<<mav)
+	let series = []
+	data.forEach((d, i) => {
+		if (active === undefined || active === i) series.push(i)
+	})
+	return {from: from, to: to, series: series}
+}
+function live() {
+	if (stream === "") return
+	let bands = []
+	let es = new EventSource(stream)
+	es.onmessage = (evt) => {
+		let m = JSON.parse(evt.data)
+		let span = end - start
+		end = m.t * 1000
+		start = end - span
+		data.forEach((d, i) => {
+			let v = m.v[d.title]
+			if (v === undefined) v = null
+			raw[i].push(v)
+			while (raw[i].length > w) raw[i].shift()
+			if (d.high) {
+				bands[i] = bands[i] || {low: unpixels(d.low, d.fmax), high: unpixels(d.high, d.fmax)}
+				bands[i].low.push(v)
+				bands[i].high.push(v)
+				while (bands[i].low.length > raw[i].length) {
+					bands[i].low.shift()
+					bands[i].high.shift()
+				}
+			}
+		})
+		rescale(bands)
+		render(active||0)
+		relabel()
+	}
+}
+function unpixels(vals, fmax) {
+	return vals.map(p => p === missing ? null : p * fmax / h)
+}
+function rescale(bands) {
+	let gmax = 0
+	data.forEach((d, i) => {
+		d.fmax = Math.max(0, ...raw[i].filter(v => v !== null))
+		if (bands[i]) d.fmax = Math.max(d.fmax, ...bands[i].high.filter(v => v !== null))
+		gmax = Math.max(gmax, d.fmax)
+	})
+	let c = document.getElementById('ygrid').children
+	data.forEach((d, i) => {
+		d.values = topixels(raw[i], d.fmax)
+		d.max = gmax ? Math.trunc(h * d.fmax / gmax) : 0
+		if (bands[i]) {
+			d.low = topixels(bands[i].low, d.fmax)
+			d.high = topixels(bands[i].high, d.fmax)
+		}
+		if (d.smooth.method) d.smoothed = topixels(smooth(raw[i], d.smooth), d.fmax)
+		for (let j=0; j<c.length; j++) {
+			let dy = c[j].children[0].getAttribute('y') - c[0].children[0].getAttribute('y')
+			d.scale[j] = fmtu(d.fmax - d.fmax / h * dy, d)
+		}
+	})
+}
+function relabel() {
+	let g = document.getElementById('grid')
+	if (!g) return
+	let c = g.children
+	for (let j=0; j<c.length; j++) {
+		let t = c[j].children[0]
+		let v = ((end-start) / w * (t.getAttribute('x')-mx) + start) / 1000
+		t.textContent = label(v, xfmt, clock) + (xfmt.unit || '')
 	}
-	status()
 }
 `
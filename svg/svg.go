@@ -4,10 +4,12 @@ package svg
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
+	"sort"
+	"time"
 
 	"github.com/tomarus/chart/data"
-	"github.com/tomarus/chart/format"
 	"github.com/tomarus/chart/image"
 	"github.com/tomarus/chart/palette"
 )
@@ -21,6 +23,10 @@ type SVG struct {
 	start, end       int64
 	pal              *palette.Palette
 	txtids           map[string][]textid
+	stream           string
+	legend           *image.Legend
+	labels           [2]image.Labels // x and y axis labels, updated by live charts
+	image.TextMetrics
 }
 
 type textid struct {
@@ -31,11 +37,25 @@ type textid struct {
 
 // New initializes a new svg chart image writer.
 func New() *SVG {
-	return &SVG{txtids: make(map[string][]textid)}
+	return &SVG{
+		txtids:      make(map[string][]textid),
+		TextMetrics: image.NewTextMetrics(),
+	}
+}
+
+// Stream enables live updates. The embedded javascript connects to the
+// Server-Sent Events endpoint at url and appends each received sample to
+// the chart, shifting the time window by one pixel per sample. The axis
+// labels are updated using the formats of axis.NewTime, NewSI and NewFloat,
+// axes with a custom formatter use a default format instead.
+// See the stream package for a compatible server.
+func (svg *SVG) Stream(url string) *SVG {
+	svg.stream = url
+	return svg
 }
 
 // Start initializes a new image and sets the defaults.
-func (svg *SVG) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection) {
+func (svg *SVG) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *image.Layout) {
 	svg.w = wr
 	svg.data = d
 	svg.width = w
@@ -45,10 +65,12 @@ func (svg *SVG) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palet
 	svg.start = start
 	svg.end = end
 	svg.pal = p
+	svg.legend = l.Legend
+	svg.labels = [2]image.Labels{l.XLabels, l.YLabels}
 
-	svg.svgHead(w+mx+4, h+(2*my)+((d.Len()+1)*16))
+	svg.svgHead(l.Width, l.Height)
 	svg.svgCSS(svg.pal)
-	svg.p(`<rect class="background" x="0" y="0" width="%d" height="%d"/>`, w+mx+32, h+(2*my)+((d.Len()+1)*16))
+	svg.p(`<rect class="background" x="0" y="0" width="%d" height="%d"/>`, l.Width, l.Height)
 }
 
 // Graph renders all chart dataset values to the visible chart area.
@@ -56,15 +78,25 @@ func (svg *SVG) Graph() error {
 	svg.p(`<defs>`)
 	{
 		svg.p(`<script type="text/javascript"><![CDATA[`)
-		svg.p("const w=%d,h=%d,mx=%d,my=%d,start=%d,end=%d", svg.width, svg.height, svg.marginx, svg.marginy, svg.start*1000, svg.end*1000)
+		svg.p("const w=%d,h=%d,mx=%d,my=%d", svg.width, svg.height, svg.marginx, svg.marginy)
+		svg.p("let start=%d,end=%d", svg.start*1000, svg.end*1000)
 		jsdata, _ := json.Marshal(svg.data)
-		svg.p("const data=" + string(jsdata))
+		svg.p("const data=%s", jsdata)
+		jsstream, _ := json.Marshal(svg.stream)
+		svg.p("const stream=%s", jsstream)
+		svg.p("const raw=%s", image.ScriptValues(svg.data))
+		svg.p("const missing=%d", data.Missing)
+		jsfmt, _ := json.Marshal(svg.labels)
+		zone, offset := time.Unix(svg.end, 0).Zone()
+		svg.p("const [xfmt,yfmt]=%s,tz=%d,tzname=%q", jsfmt, offset, zone)
 		fmt.Fprint(svg.w, js)
 		svg.p("]]></script>")
 
 		for i := range svg.data {
 			svg.p(`<g id="path%d">`, i+1)
+			svg.p(`<path style="fill: none; stroke: %s; stroke-opacity: .35; shape-rendering: crispEdges" d="M0,0"/>`, svg.pal.GetHexAxisColor(i))
 			svg.p(`<path style="fill: none; stroke: %s; shape-rendering: crispEdges" d="M0,0"/>`, svg.pal.GetHexAxisColor(i))
+			svg.p(`<path style="fill: none; stroke: %s; stroke-width: 2; shape-rendering: auto" d="M0,0"/>`, svg.pal.GetHexColor("marker"))
 			svg.p(`</g>`)
 		}
 	}
@@ -96,6 +128,13 @@ func (svg *SVG) drawMA() {
 
 	y := svg.height + svg.marginy + 4
 	svg.p(`<rect id="mabut" x="%d" y="%d" width="12" height="12" style="visibility:normal;fill:%s"/>`, svg.width+svg.marginx-12, y, svg.pal.GetHexColor(maColor))
+	svg.drawExport(svg.width+svg.marginx-20, y+11)
+}
+
+// drawExport draws the csv and json data export buttons, right aligned to x.
+func (svg *SVG) drawExport(x, y int) {
+	svg.p(`<g id="csvbut" class="title2 gridfont legend"><text style="text-anchor:end" x="%d" y="%d">csv</text></g>`, x, y)
+	svg.p(`<g id="jsonbut" class="title2 gridfont legend"><text style="text-anchor:end" x="%d" y="%d">json</text></g>`, x-32, y)
 }
 
 // Text writes a string to the image.
@@ -116,7 +155,7 @@ func (svg *SVG) Text(color, align string, role image.TextRole, x, y int, txt str
 		class += " gridfont"
 
 	}
-	svg.p(`<g class="%s"><text style="%s" x="%d" y="%d">%s</text></g>`, class, anchor, x, y, txt)
+	svg.p(`<g class="%s"><text style="%s" x="%d" y="%d">%s</text></g>`, class, anchor, x, y, html.EscapeString(txt))
 }
 
 // TextID writes a string to the image using an id.
@@ -127,10 +166,17 @@ func (svg *SVG) TextID(id, color, align string, role image.TextRole, x, y int, t
 	svg.txtids[id] = append(svg.txtids[id], textid{color, align, txt, x, y, role})
 }
 
+// drawTextIDs draws the text grouped by id, sorted by id so the same chart
+// always results in the same image.
 func (svg *SVG) drawTextIDs() {
-	for k, v := range svg.txtids {
+	ids := make([]string, 0, len(svg.txtids))
+	for k := range svg.txtids {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
+	for _, k := range ids {
 		svg.p(`<g id="%s">`, k)
-		for _, t := range v {
+		for _, t := range svg.txtids[k] {
 			svg.Text(t.color, t.align, t.role, t.x, t.y, t.txt)
 		}
 		svg.p(`</g>`)
@@ -142,29 +188,23 @@ func (svg *SVG) Line(color string, x1, y1, x2, y2 int) {
 	svg.p(`<line class="%s" x1="%d" y1="%d" x2="%d" y2="%d"/>`, color, x1, y1, x2, y2)
 }
 
-// Legend writes the legend buttons to the bottom.
-func (svg *SVG) Legend(base float64) {
-	x := svg.marginx
-	y := svg.height + svg.marginy + 4 + 16
-
-	q := "Min     Max     Avg"
-	svg.Text("title2", "right", image.GridRole, x+svg.width, y+11, q)
-	y += 16
+// Legend writes the legend buttons.
+func (svg *SVG) Legend() {
+	l := svg.legend
+	if !l.Visible() {
+		return
+	}
+	for c := 0; c < l.Columns; c++ {
+		svg.Text("title2", "right", image.GridRole, l.Column(c)+l.ColWidth, l.Y+11, l.Header())
+	}
 
 	for i, d := range svg.data {
+		x, y := l.Entry(i)
 		id := fmt.Sprintf("path%d_b", i+1)
 		svg.p(`<g id="%s" class="legend"><rect x="%d" y="%d" width="12" height="12" style="visibility:normal;fill:%s"/></g>`, id, x, y, svg.pal.GetHexAxisColor(i))
 		svg.Text("title", "left", image.GridRole, x+20, y+11, d.Title)
-
-		// FIXME use axis formatters for this.
-		min, max, avg := d.MinMaxAvg()
-		mmax := format.SI(max, 1, base, "", "", "")
-		mmin := format.SI(min, 1, base, "", "", "")
-		mavg := format.SI(avg, 1, base, "", "", "")
-		q := fmt.Sprintf("%6s  %6s  %6s", mmin, mmax, mavg)
-		svg.Text("title", "right", image.GridRole, x+svg.width, y+11, q)
-		svg.Line("grid2", x, y+11+3, x+svg.width, y+11+3)
-		y += 16
+		svg.Text("title", "right", image.GridRole, x+l.ColWidth, y+11, l.Stats(i))
+		svg.Line("grid2", x, y+11+3, x+l.ColWidth, y+11+3)
 	}
 }
 
@@ -197,12 +237,18 @@ func (svg *SVG) svgCSS(p *palette.Palette) {
 
 	svg.p(".title { fill: %s; fill-opacity: .75 }", p.GetHexColor("title"))
 	svg.p(".title2 { fill: %s; fill-opacity: .75 }", p.GetHexColor("title2"))
-	svg.p(".titlefont { font-variant: small-caps; font-style: italic; font-size: 18px; font-family: menlo; }")
-	svg.p(".gridfont { font-size: 13px; font-family: menlo; stroke-width: .33; }")
+	tf, gf := svg.Fonts[image.TitleRole], svg.Fonts[image.GridRole]
+	tfam, twt := tf.CSS()
+	gfam, gwt := gf.CSS()
+	svg.p(".titlefont { font-variant: small-caps; font-style: italic; font-size: %gpx; font-family: %s; font-weight: %s; }", tf.Size, tfam, twt)
+	svg.p(".gridfont { font-size: %gpx; font-family: %s; font-weight: %s; stroke-width: .33; }", gf.Size, gfam, gwt)
 
 	svg.p(".border { stroke: %s; stroke-opacity: .666; fill: none }", p.GetHexColor("border"))
 	svg.p(".marker { stroke: %s; stroke-opacity: 1; stroke-width: 1; fill: none; }", p.GetHexColor("marker"))
 	svg.p(".background { fill: %s }", p.GetHexColor("background"))
+	for i := range svg.data {
+		svg.p(".%s { stroke: %s; fill: %s }", p.GetAxisColorName(i), p.GetHexAxisColor(i), p.GetHexAxisColor(i))
+	}
 	svg.p(".legend { cursor: pointer }")
 	svg.p("text { white-space: pre }")
 	svg.p("]]></style></defs>")
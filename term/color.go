@@ -0,0 +1,81 @@
+package term
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Color depths of the ANSI escape sequences.
+const (
+	NoColor   = 0       // no escape sequences
+	Color256  = 256     // xterm 256 color palette
+	TrueColor = 1 << 24 // 24 bit colors
+)
+
+// cube are the channel levels of the xterm 6x6x6 color cube.
+var cube = []int{0, 95, 135, 175, 215, 255}
+
+// rgb returns the 8 bit color channels of c blended over the background bg.
+// Terminals do not support transparency.
+func rgb(c, bg color.Color) (r, g, b int) {
+	cr, cg, cb, ca := c.RGBA()
+	br, bgg, bb, _ := bg.RGBA()
+	blend := func(c, b uint32) int {
+		return int((c + b*(0xffff-ca)/0xffff) >> 8)
+	}
+	return blend(cr, br), blend(cg, bgg), blend(cb, bb)
+}
+
+// nearest returns the index of the level nearest to v.
+func nearest(levels []int, v int) int {
+	n := 0
+	for i := range levels {
+		if abs(levels[i]-v) < abs(levels[n]-v) {
+			n = i
+		}
+	}
+	return n
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// xterm256 returns the xterm color nearest to r, g, b. Only the color cube
+// and the grayscale ramp are used, the first 16 colors are often redefined
+// by the terminal theme.
+func xterm256(r, g, b int) int {
+	ri, gi, bi := nearest(cube, r), nearest(cube, g), nearest(cube, b)
+	dist := func(r2, g2, b2 int) int {
+		return (r-r2)*(r-r2) + (g-g2)*(g-g2) + (b-b2)*(b-b2)
+	}
+	c := 16 + 36*ri + 6*gi + bi
+	d := dist(cube[ri], cube[gi], cube[bi])
+
+	gray := (r + g + b) / 3
+	gi = (gray - 8 + 5) / 10
+	if gi < 0 {
+		gi = 0
+	} else if gi > 23 {
+		gi = 23
+	}
+	if v := 8 + 10*gi; dist(v, v, v) < d {
+		c = 232 + gi
+	}
+	return c
+}
+
+// sgr returns the escape sequence which selects the foreground (38) or
+// background (48) color c at the given color depth.
+func sgr(fgbg, depth int, r, g, b int) string {
+	switch depth {
+	case TrueColor:
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", fgbg, r, g, b)
+	case Color256:
+		return fmt.Sprintf("\x1b[%d;5;%dm", fgbg, xterm256(r, g, b))
+	}
+	return ""
+}
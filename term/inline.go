@@ -0,0 +1,162 @@
+package term
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	stdpng "image/png"
+	"io"
+
+	"github.com/tomarus/chart/data"
+	myimg "github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+	"github.com/tomarus/chart/png"
+)
+
+// Inline image protocols.
+const (
+	Sixel = "sixel" // DEC sixel graphics, supported by xterm -ti vt340, mlterm, WezTerm and foot
+	Kitty = "kitty" // kitty graphics protocol, supported by kitty, WezTerm and Konsole
+)
+
+// Inline draws charts using the png rasterizer and writes them as an inline
+// image escape sequence for terminals which support bitmap graphics.
+type Inline struct {
+	*png.PNG
+	w        io.Writer
+	protocol string
+	pal      *palette.Palette
+}
+
+// NewSixel initializes a new chart writer which writes sixel graphics.
+// The image is quantized to 256 colors, keeping the palette colors exact.
+func NewSixel() *Inline {
+	return &Inline{PNG: png.New(), protocol: Sixel}
+}
+
+// NewKitty initializes a new chart writer which writes a png image using
+// the kitty graphics protocol.
+func NewKitty() *Inline {
+	return &Inline{PNG: png.New(), protocol: Kitty}
+}
+
+// Start initializes a new image and sets the defaults.
+func (in *Inline) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *myimg.Layout) {
+	in.w = wr
+	in.pal = p
+	in.PNG.Start(wr, w, h, mx, my, start, end, p, d, l)
+}
+
+// End finishes and writes the escape sequence to the output writer.
+func (in *Inline) End() error {
+	w := bufio.NewWriter(in.w)
+	var err error
+	switch in.protocol {
+	case Kitty:
+		err = kitty(w, in.Image())
+	default:
+		q := in.pal.Quantizer().Quantize(make(color.Palette, 0, 256), in.Image())
+		err = sixel(w, palette.Paletted(in.Image(), q))
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// kitty writes m as png using the kitty graphics protocol. The payload is
+// sent in chunks of at most 4096 bytes.
+func kitty(w io.Writer, m image.Image) error {
+	var buf bytes.Buffer
+	if err := stdpng.Encode(&buf, m); err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+	for i := 0; i < len(payload); i += 4096 {
+		chunk := payload[i:min(i+4096, len(payload))]
+		more := 0
+		if i+4096 < len(payload) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// sixel writes m as sixel graphics. Each band of six rows is written once
+// for every color used in the band, runs of the same sixel are compressed.
+func sixel(w io.Writer, m *image.Paletted) error {
+	b := m.Bounds()
+	fmt.Fprintf(w, "\x1bPq\"1;1;%d;%d", b.Dx(), b.Dy())
+	for i, c := range m.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	bits := make([]byte, b.Dx()*len(m.Palette))
+	used := make([]bool, len(m.Palette))
+	for y := b.Min.Y; y < b.Max.Y; y += 6 {
+		for i := range bits {
+			bits[i] = 0
+		}
+		for i := range used {
+			used[i] = false
+		}
+		for dy := 0; dy < 6 && y+dy < b.Max.Y; dy++ {
+			row := m.Pix[m.PixOffset(b.Min.X, y+dy):]
+			for x := 0; x < b.Dx(); x++ {
+				c := row[x]
+				bits[int(c)*b.Dx()+x] |= 1 << uint(dy)
+				used[c] = true
+			}
+		}
+		first := true
+		for c := range used {
+			if !used[c] {
+				continue
+			}
+			if !first {
+				fmt.Fprint(w, "$")
+			}
+			first = false
+			fmt.Fprintf(w, "#%d", c)
+			run(w, bits[c*b.Dx():(c+1)*b.Dx()])
+		}
+		fmt.Fprint(w, "-")
+	}
+	_, err := fmt.Fprint(w, "\x1b\\\n")
+	return err
+}
+
+// run writes the sixels of a single color of a band, repeated sixels are
+// written as !count followed by the sixel. Trailing empty sixels are not
+// written.
+func run(w io.Writer, bits []byte) {
+	for len(bits) > 0 && bits[len(bits)-1] == 0 {
+		bits = bits[:len(bits)-1]
+	}
+	for i := 0; i < len(bits); {
+		n := 1
+		for i+n < len(bits) && bits[i+n] == bits[i] {
+			n++
+		}
+		ch := rune(63 + bits[i])
+		switch {
+		case n > 3:
+			fmt.Fprintf(w, "!%d%c", n, ch)
+		default:
+			for j := 0; j < n; j++ {
+				fmt.Fprintf(w, "%c", ch)
+			}
+		}
+		i += n
+	}
+}
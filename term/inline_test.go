@@ -0,0 +1,150 @@
+package term
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	stdpng "image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tomarus/chart/data"
+	myimg "github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+// testInline renders two datasets using img.
+func testInline(img *Inline) (*bytes.Buffer, error) {
+	p, _ := palette.NewPalette("white")
+	d := data.Collection{
+		data.NewData(&data.Options{}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}),
+		data.NewData(&data.Options{}, []float64{5, 4, 3, 2, 1, 2, 3, 4, 5, 6}),
+	}
+	d.Normalize(40)
+	var out bytes.Buffer
+	img.Start(&out, 10, 40, 5, 7, 0, 3600, p, d, &myimg.Layout{Width: 20, Height: 54})
+	if err := img.Graph(); err != nil {
+		return nil, err
+	}
+	img.Text("title", "left", myimg.GridRole, 0, 10, "x")
+	img.Border(4, 6, 11, 41)
+	return &out, img.End()
+}
+
+// desixel decodes the sixel graphics written by sixel.
+func desixel(t *testing.T, s string) *image.Paletted {
+	m := regexp.MustCompile(`^\x1bPq"1;1;(\d+);(\d+)`).FindStringSubmatch(s)
+	if m == nil || !strings.HasSuffix(s, "\x1b\\\n") {
+		t.Fatalf("Expected sixel sequence, got %q", s[:20])
+	}
+	w, _ := strconv.Atoi(m[1])
+	h, _ := strconv.Atoi(m[2])
+	s = strings.TrimSuffix(s[len(m[0]):], "\x1b\\\n")
+
+	var pal color.Palette
+	regs := regexp.MustCompile(`^#(\d+);2;(\d+);(\d+);(\d+)`)
+	for r := regs.FindStringSubmatch(s); r != nil; r = regs.FindStringSubmatch(s) {
+		c := [3]uint8{}
+		for i := range c {
+			v, _ := strconv.Atoi(r[i+2])
+			c[i] = uint8(v * 255 / 100)
+		}
+		pal = append(pal, color.RGBA{c[0], c[1], c[2], 255})
+		s = s[len(r[0]):]
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, w, h), pal)
+	x, y, c := 0, 0, 0
+	for i := 0; i < len(s); i++ {
+		n := 1
+		switch ch := s[i]; {
+		case ch == '#':
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			c, _ = strconv.Atoi(s[i+1 : j])
+			i = j - 1
+			continue
+		case ch == '$':
+			x = 0
+			continue
+		case ch == '-':
+			x, y = 0, y+6
+			continue
+		case ch == '!':
+			j := i + 1
+			for s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			n, _ = strconv.Atoi(s[i+1 : j])
+			i = j
+		}
+		bits := s[i] - 63
+		for ; n > 0; n-- {
+			for dy := 0; dy < 6; dy++ {
+				if bits&(1<<uint(dy)) != 0 {
+					img.SetColorIndex(x, y+dy, uint8(c))
+				}
+			}
+			x++
+		}
+	}
+	return img
+}
+
+func TestSixel(t *testing.T) {
+	img := NewSixel()
+	out, err := testInline(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := desixel(t, out.String())
+	want := img.Image().(*image.RGBA)
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("Expected bounds %v, got %v", want.Bounds(), got.Bounds())
+	}
+	// Colors are equal up to the precision of sixel colors.
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			r1, g1, b1, _ := want.At(x, y).RGBA()
+			r2, g2, b2, _ := got.At(x, y).RGBA()
+			if d := dist(r1, r2) + dist(g1, g2) + dist(b1, b2); d > 3*0x0400 {
+				t.Fatalf("Pixel %d,%d differs: %v != %v", x, y, want.At(x, y), got.At(x, y))
+			}
+		}
+	}
+}
+
+func dist(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestKitty(t *testing.T) {
+	img := NewKitty()
+	out, err := testInline(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload string
+	for _, m := range regexp.MustCompile(`\x1b_G([^;]*);([^\x1b]*)\x1b\\`).FindAllStringSubmatch(out.String(), -1) {
+		payload += m[2]
+	}
+	b, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := stdpng.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != img.Image().Bounds() {
+		t.Errorf("Expected bounds %v, got %v", img.Image().Bounds(), m.Bounds())
+	}
+}
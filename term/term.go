@@ -0,0 +1,562 @@
+// Package term provides the terminal interface for tomarus chart lib.
+// Charts are drawn using unicode braille or block characters, or plain
+// ascii, colored using ANSI escape sequences.
+package term
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+// missing is the pixel value of missing values.
+const missing = data.Missing
+
+// The chart is laid out in pixels, each character cell is cellW by cellH
+// pixels. This is about the size of a terminal font, so the text, the
+// margins and the legend rows of the chart map well onto cells.
+const (
+	cellW = 8
+	cellH = 16
+)
+
+// Character sets used to draw the chart.
+const (
+	Braille = "braille" // braille patterns, 2x4 dots per cell
+	Blocks  = "blocks"  // lower eighth blocks, 1x8 per cell
+	ASCII   = "ascii"   // plain ascii
+)
+
+// glyphs are the characters used to draw lines and borders.
+type glyphs struct {
+	hline, vline, hgrid, vgrid, tl, tr, bl, br, swatch rune
+}
+
+var (
+	unicodeGlyphs = glyphs{'─', '│', '┈', '┊', '┌', '┐', '└', '┘', '█'}
+	asciiGlyphs   = glyphs{'-', '|', '.', ':', '+', '+', '+', '+', '#'}
+)
+
+// blocks are the lower eighth blocks by the number of eighths.
+var blocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// marks are the characters of each dataset in ascii charts.
+var marks = []rune("#*o%")
+
+// cell is a single character cell of the terminal.
+type cell struct {
+	ch     rune
+	fg, bg color.Color // nil uses the terminal default
+	used   bool        // grid lines are only drawn in unused cells
+}
+
+// Term implements the chart interface to write charts to a terminal.
+type Term struct {
+	w                io.Writer
+	cols, rows       int
+	charset          string
+	depth            int
+	data             data.Collection
+	width, height    int
+	marginx, marginy int
+	start, end       int64
+	pal              *palette.Palette
+	legend           *image.Legend
+	cells            [][]cell
+	c0, r0, c1, r1   int // cells of the border around the chart area
+}
+
+// New initializes a new terminal chart writer of cols by rows characters.
+// By default braille characters and 256 colors are used.
+func New(cols, rows int) *Term {
+	return &Term{cols: cols, rows: rows, charset: Braille, depth: Color256}
+}
+
+// Charset sets the characters used to draw the chart, Braille, Blocks or
+// ASCII. Use ASCII for terminals or fonts without unicode support.
+func (t *Term) Charset(s string) *Term {
+	t.charset = s
+	return t
+}
+
+// Colors sets the color depth of the ANSI escape sequences, NoColor,
+// Color256 or TrueColor. Palette colors are mapped to the nearest
+// terminal color.
+func (t *Term) Colors(depth int) *Term {
+	t.depth = depth
+	return t
+}
+
+// Fit returns the size of the terminal in pixels. The chart is sized to fit.
+func (t *Term) Fit() (w, h int) {
+	return t.cols * cellW, t.rows * cellH
+}
+
+// Font is a no-op, terminals use their own font.
+func (t *Term) Font(role image.TextRole, f image.Font) error {
+	return nil
+}
+
+// MeasureText returns the width and height in pixels of a string.
+func (t *Term) MeasureText(role image.TextRole, txt string) (w, h int) {
+	return utf8.RuneCountInString(txt) * cellW, cellH
+}
+
+// Start initializes a new image and sets the defaults.
+func (t *Term) Start(wr io.Writer, w, h, mx, my int, start, end int64, p *palette.Palette, d data.Collection, l *image.Layout) {
+	t.w = wr
+	t.data = d
+	t.width = w
+	t.height = h
+	t.marginx = mx
+	t.marginy = my
+	t.start = start
+	t.end = end
+	t.pal = p
+	t.legend = l.Legend
+
+	var bg color.Color
+	if t.depth != NoColor {
+		bg = p.GetColor("background")
+	}
+	t.cells = make([][]cell, t.rows)
+	for y := range t.cells {
+		t.cells[y] = make([]cell, t.cols)
+		for x := range t.cells[y] {
+			t.cells[y][x] = cell{ch: ' ', bg: bg}
+		}
+	}
+	t.c0, t.r0 = (mx-1)/cellW, (my-1)/cellH
+	t.c1, t.r1 = (mx+w)/cellW, (my+h)/cellH
+	if t.c1 >= t.cols {
+		t.c1 = t.cols - 1
+	}
+	if t.r1 >= t.rows {
+		t.r1 = t.rows - 1
+	}
+}
+
+// End finishes and writes the chart to the output writer.
+func (t *Term) End() error {
+	w := bufio.NewWriter(t.w)
+	background := t.pal.GetColor("background")
+	cache := map[color.Color]string{}
+	escape := func(fgbg int, c color.Color) string {
+		if c == nil || t.depth == NoColor {
+			return ""
+		}
+		key := c
+		if fgbg == 48 {
+			key = bgcolor{c}
+		}
+		if s, ok := cache[key]; ok {
+			return s
+		}
+		r, g, b := rgb(c, background)
+		cache[key] = sgr(fgbg, t.depth, r, g, b)
+		return cache[key]
+	}
+	for _, row := range t.cells {
+		var line strings.Builder
+		fg, bg := "", ""
+		for _, c := range row {
+			if f := escape(38, c.fg); f != fg && c.ch != ' ' {
+				line.WriteString(f)
+				fg = f
+			}
+			if b := escape(48, c.bg); b != bg {
+				line.WriteString(b)
+				bg = b
+			}
+			line.WriteRune(c.ch)
+		}
+		s := line.String()
+		if t.depth == NoColor {
+			s = strings.TrimRight(s, " ")
+		} else {
+			s += "\x1b[0m"
+		}
+		fmt.Fprintln(w, s)
+	}
+	return w.Flush()
+}
+
+// bgcolor distinguishes background from foreground escape sequences in
+// the cache of End.
+type bgcolor struct {
+	color.Color
+}
+
+// glyphs returns the line characters of the charset.
+func (t *Term) glyphs() glyphs {
+	if t.charset == ASCII {
+		return asciiGlyphs
+	}
+	return unicodeGlyphs
+}
+
+// color returns the palette color name, or nil if colors are disabled.
+func (t *Term) color(name string) color.Color {
+	if t.depth == NoColor {
+		return nil
+	}
+	return t.pal.GetColor(name)
+}
+
+// set sets the character of a cell if it is on the terminal.
+func (t *Term) set(x, y int, ch rune, fg, bg color.Color) {
+	if x < 0 || y < 0 || x >= t.cols || y >= t.rows {
+		return
+	}
+	c := &t.cells[y][x]
+	c.ch, c.fg, c.used = ch, fg, true
+	if bg != nil {
+		c.bg = bg
+	}
+}
+
+// col returns the cell column of pixel x. Positions in the chart area are
+// kept within the border, positions outside of it are kept clear of the
+// border.
+func (t *Term) col(x int) int {
+	switch {
+	case x < t.marginx:
+		return min(x/cellW, t.c0-1)
+	case x > t.marginx+t.width:
+		return max(x/cellW, t.c1+1)
+	}
+	return max(min(x/cellW, t.c1-1), t.c0+1)
+}
+
+// row returns the cell row of pixel y, see col.
+func (t *Term) row(y int) int {
+	switch {
+	case y < t.marginy:
+		return min(y/cellH, t.r0-1)
+	case y > t.marginy+t.height:
+		return max(y/cellH, t.r1+1)
+	}
+	return max(min(y/cellH, t.r1-1), t.r0+1)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Graph renders all chart dataset values to the visible chart area.
+// The values are first drawn into a grid of dots, a number of dots per
+// cell depending on the charset, which is then converted to characters.
+func (t *Term) Graph() error {
+	sx, sy := 1, 8
+	if t.charset == Braille {
+		sx, sy = 2, 4
+	}
+	cols, rows := t.c1-t.c0-1, t.r1-t.r0-1
+	if cols <= 0 || rows <= 0 {
+		return nil
+	}
+	dots := &dots{w: cols * sx, h: rows * sy, v: make([]int, cols*sx*rows*sy)}
+
+	// Dots are aligned to the cells, the few pixels of the chart area
+	// which are not within whole cells are not drawn.
+	dw, dh := cellW/sx, cellH/sy
+	left := (t.c0+1)*cellW - t.marginx
+	bottom := t.marginy + t.height - t.r1*cellH
+	span := func(v []int, x int) []int {
+		p := min(left+x*dw, len(v))
+		return v[p:min(p+dw, len(v))]
+	}
+	height := func(v int, a, b float64) int {
+		return int(math.Round((float64(v)*a + b - float64(bottom)) / float64(dh)))
+	}
+
+	// Dots are set to the index of the dataset plus one, datasets drawn
+	// later are on top. The smoothed lines are drawn on top of all datasets.
+	colors := []color.Color{nil}
+	for pt, d := range t.data {
+		colors = append(colors, t.color(t.pal.GetAxisColorName(pt)))
+		a := float64(d.NMax) / float64(t.height)
+		b := float64(d.NMax) - a*float64(t.height)
+		for x := 0; x < dots.w; x++ {
+			if len(d.High) > 0 {
+				lo, _ := pixels(span(d.Low, x))
+				_, hi := pixels(span(d.High, x))
+				if lo != missing {
+					dots.fill(x, height(lo, a, b), height(hi, a, b)+1, pt+1)
+				}
+				continue
+			}
+			lo, hi := pixels(span(d.Values, x))
+			if lo < 0 && lo != missing {
+				return fmt.Errorf("Negative values not supported")
+			}
+			if hi != missing {
+				dots.fill(x, 0, height(hi, a, b), pt+1)
+			}
+		}
+	}
+	colors = append(colors, t.color("marker"))
+	for _, d := range t.data {
+		a := float64(d.NMax) / float64(t.height)
+		b := float64(d.NMax) - a*float64(t.height)
+		prev := missing
+		for x := 0; x < dots.w && len(d.Smoothed) > 0; x++ {
+			lo, hi := pixels(span(d.Smoothed, x))
+			if lo == missing {
+				prev = missing
+				continue
+			}
+			y := height((lo+hi)/2, a, b)
+			if prev == missing {
+				prev = y
+			}
+			dots.fill(x, min(y, prev), max(y, prev)+1, len(colors)-1)
+			prev = y
+		}
+	}
+
+	for cy := 0; cy < rows; cy++ {
+		for cx := 0; cx < cols; cx++ {
+			ch, fg, bg := t.glyph(dots, cx*sx, cy*sy, sx, sy, colors)
+			if ch != 0 {
+				t.set(t.c0+1+cx, t.r0+1+cy, ch, fg, bg)
+			}
+		}
+	}
+	return nil
+}
+
+// pixels returns the minimum and maximum pixel value of v, ignoring
+// missing values.
+func pixels(v []int) (lo, hi int) {
+	lo, hi = missing, missing
+	for _, p := range v {
+		if p == missing {
+			continue
+		}
+		if lo == missing || p < lo {
+			lo = p
+		}
+		if hi == missing || p > hi {
+			hi = p
+		}
+	}
+	return lo, hi
+}
+
+// dots is a grid of w by h dots, the first row is the top of the chart.
+type dots struct {
+	w, h int
+	v    []int
+}
+
+// fill sets the dots of column x from lo up to hi to v. Zero is the bottom
+// of the chart.
+func (d *dots) fill(x, lo, hi, v int) {
+	lo, hi = max(lo, 0), min(hi, d.h)
+	for y := lo; y < hi; y++ {
+		d.v[(d.h-1-y)*d.w+x] = v
+	}
+}
+
+func (d *dots) at(x, y int) int {
+	return d.v[y*d.w+x]
+}
+
+// braille are the bits of the braille dots by row and column.
+var braille = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+// glyph returns the character and colors of the cell with the top left dot
+// at x, y. It returns 0 for empty cells.
+func (t *Term) glyph(d *dots, x, y, sx, sy int, colors []color.Color) (rune, color.Color, color.Color) {
+	// Use the color of the dataset with the most dots in the cell. Later
+	// datasets win ties, smoothed lines always win.
+	count := make([]int, len(colors))
+	top, n := 0, 0
+	for dy := 0; dy < sy; dy++ {
+		for dx := 0; dx < sx; dx++ {
+			if v := d.at(x+dx, y+dy); v != 0 {
+				count[v]++
+				n++
+			}
+		}
+	}
+	for v := range count {
+		if count[v] > 0 && count[v] >= count[top] {
+			top = v
+		}
+	}
+	if m := len(colors) - 1; count[m] > 0 {
+		top = m
+	}
+	if n == 0 {
+		return 0, nil, nil
+	}
+
+	switch t.charset {
+	case Braille:
+		ch := rune(0x2800)
+		for dy := 0; dy < sy; dy++ {
+			for dx := 0; dx < sx; dx++ {
+				if d.at(x+dx, y+dy) != 0 {
+					ch |= braille[dy][dx]
+				}
+			}
+		}
+		return ch, colors[top], nil
+	case ASCII:
+		if n < sy/2 {
+			return '_', colors[top], nil
+		}
+		return marks[(top-1)%len(marks)], colors[top], nil
+	}
+
+	// Blocks are drawn from the bottom in the color of the lowest dot, the
+	// color above it is used as the background.
+	lower := d.at(x, y+sy-1)
+	e := 0
+	for e < sy && d.at(x, y+sy-1-e) == lower {
+		e++
+	}
+	if e == sy {
+		return blocks[sy], colors[lower], nil
+	}
+	upper := d.at(x, y+sy-1-e)
+	if lower == 0 {
+		// a band not starting at the bottom of the cell
+		if sy-e >= sy/2 {
+			return '▀', colors[upper], nil
+		}
+		return '▔', colors[upper], nil
+	}
+	bg := colors[upper]
+	if upper == 0 {
+		bg = t.color("background")
+	}
+	return blocks[e], colors[lower], bg
+}
+
+// Text writes a string to the image.
+func (t *Term) Text(col, align string, role image.TextRole, x, y int, txt string) {
+	r := []rune(txt)
+	cx := t.col(x)
+	switch align {
+	case "middle", "center":
+		cx -= len(r) / 2
+	case "end", "right":
+		cx = t.col(x-1) - len(r) + 1
+	}
+	// y is the baseline of the text, use the middle of the text
+	cy := t.row(y - cellH/4)
+	for i := range r {
+		t.set(cx+i, cy, r[i], t.color(col), t.color("background"))
+	}
+}
+
+// TextID writes a string to the image.
+func (t *Term) TextID(id, col, align string, role image.TextRole, x, y int, txt string) {
+	t.Text(col, align, role, x, y, txt)
+}
+
+// Line draws a horizontal or vertical line using the color name from the
+// palette. Grid lines are drawn below the graph and the text, other lines
+// are drawn on top. The sub grid is not drawn, it is too dense for the
+// resolution of a terminal.
+func (t *Term) Line(col string, x1, y1, x2, y2 int) {
+	if col == "grid2" {
+		return
+	}
+	g := t.glyphs()
+	grid := col == "grid"
+	fg := t.color(col)
+	draw := func(x, y int, ch rune) {
+		if x < 0 || y < 0 || x >= t.cols || y >= t.rows {
+			return
+		}
+		if grid && t.cells[y][x].used {
+			return
+		}
+		t.set(x, y, ch, fg, nil)
+		t.cells[y][x].used = !grid
+	}
+	switch {
+	case x1 == x2:
+		ch := g.vline
+		if grid {
+			ch = g.vgrid
+		}
+		x := t.col(x1)
+		for y := t.row(min(y1, y2)); y <= t.row(max(y1, y2)); y++ {
+			draw(x, y, ch)
+		}
+	case y1 == y2:
+		ch := g.hline
+		if grid {
+			ch = g.hgrid
+		}
+		y := t.row(y1)
+		for x := t.col(min(x1, x2)); x <= t.col(max(x1, x2)); x++ {
+			draw(x, y, ch)
+		}
+	}
+}
+
+// Legend draws the terminal specific legend.
+func (t *Term) Legend() {
+	l := t.legend
+	if !l.Visible() {
+		return
+	}
+	for c := 0; c < l.Columns; c++ {
+		t.Text("title2", "right", image.GridRole, l.Column(c)+l.ColWidth, l.Y+10, l.Header())
+	}
+
+	g := t.glyphs()
+	for i, d := range t.data {
+		x, y := l.Entry(i)
+		swatch := g.swatch
+		if t.charset == ASCII {
+			swatch = marks[i%len(marks)]
+		}
+		t.set(t.col(x), t.row(y+10-cellH/4), swatch, t.color(t.pal.GetAxisColorName(i)), nil)
+		t.Text("title", "left", image.GridRole, x+20, y+10, d.Title)
+		t.Text("title", "right", image.GridRole, x+l.ColWidth, y+10, l.Stats(i))
+	}
+}
+
+// Border draws a border around the chart area. The border is drawn on the
+// cells around the chart area, x, y, w and h are not used.
+func (t *Term) Border(x, y, w, h int) {
+	g := t.glyphs()
+	fg := t.color("border")
+	for cx := t.c0 + 1; cx < t.c1; cx++ {
+		t.set(cx, t.r0, g.hline, fg, nil)
+		t.set(cx, t.r1, g.hline, fg, nil)
+	}
+	for cy := t.r0 + 1; cy < t.r1; cy++ {
+		t.set(t.c0, cy, g.vline, fg, nil)
+		t.set(t.c1, cy, g.vline, fg, nil)
+	}
+	t.set(t.c0, t.r0, g.tl, fg, nil)
+	t.set(t.c1, t.r0, g.tr, fg, nil)
+	t.set(t.c0, t.r1, g.bl, fg, nil)
+	t.set(t.c1, t.r1, g.br, fg, nil)
+}
@@ -0,0 +1,80 @@
+package term
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+	"github.com/tomarus/chart/palette"
+)
+
+func TestXterm256(t *testing.T) {
+	for _, c := range []struct {
+		r, g, b int
+		want    int
+	}{
+		{0, 0, 0, 16},
+		{255, 255, 255, 231},
+		{255, 0, 0, 196},
+		{0, 0, 255, 21},
+		{128, 128, 128, 244},
+		{240, 240, 240, 255},
+	} {
+		if got := xterm256(c.r, c.g, c.b); got != c.want {
+			t.Errorf("Expected %d,%d,%d to be color %d, got %d", c.r, c.g, c.b, c.want, got)
+		}
+	}
+}
+
+func TestRGB(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	if r, g, b := rgb(color.RGBA{0, 0, 0, 0}, white); r != 255 || g != 255 || b != 255 {
+		t.Errorf("Expected transparent color to be the background, got %d,%d,%d", r, g, b)
+	}
+	if r, g, b := rgb(color.NRGBA{0, 0, 0, 128}, white); r != 127 || g != 127 || b != 127 {
+		t.Errorf("Expected gray, got %d,%d,%d", r, g, b)
+	}
+}
+
+// testTerm renders a dataset of constant values v, of a maximum of 64, into
+// a chart area of 8 by 4 cells within the border, without legend.
+func testTerm(charset string, v int) []string {
+	p, _ := palette.NewPalette("white")
+	vals := make([]float64, 64)
+	for i := range vals {
+		vals[i] = float64(v)
+	}
+	d := data.Collection{data.NewData(&data.Options{}, vals), data.NewData(&data.Options{}, []float64{64})}
+	d.Normalize(64)
+	d = d[:1]
+
+	var out bytes.Buffer
+	t := New(10, 6).Charset(charset).Colors(NoColor)
+	t.Start(&out, 64, 64, 8, 16, 0, 3600, p, d, &image.Layout{Width: 80, Height: 96})
+	t.Graph()
+	t.Border(7, 15, 65, 65)
+	t.End()
+	return strings.Split(out.String(), "\n")
+}
+
+func TestGraph(t *testing.T) {
+	for _, c := range []struct {
+		charset string
+		v       int
+		want    []string
+	}{
+		{Braille, 64, []string{"┌────────┐", "│⣿⣿⣿⣿⣿⣿⣿⣿│", "│⣿⣿⣿⣿⣿⣿⣿⣿│", "│⣿⣿⣿⣿⣿⣿⣿⣿│", "│⣿⣿⣿⣿⣿⣿⣿⣿│", "└────────┘"}},
+		{Blocks, 20, []string{"┌────────┐", "│        │", "│        │", "│▂▂▂▂▂▂▂▂│", "│████████│", "└────────┘"}},
+		{ASCII, 36, []string{"+--------+", "|        |", "|________|", "|########|", "|########|", "+--------+"}},
+	} {
+		lines := testTerm(c.charset, c.v)
+		for i, want := range c.want {
+			if lines[i] != want {
+				t.Errorf("%s: Expected line %d to be %q, got %q", c.charset, i, want, lines[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package chart
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tomarus/chart/data"
+	"github.com/tomarus/chart/image"
+)
+
+// TimelapseOptions defines the sliding window of a timelapse animation.
+type TimelapseOptions struct {
+	Window int     // number of source values shown in each frame
+	Step   int     // number of values the window moves each frame, default a tenth of Window
+	FPS    float64 // frames per second, default 10
+}
+
+// Timelapse renders an animation of the data instead of a single image,
+// replaying how it evolved. Each frame shows Window values of the source
+// data, the start and end of the time axis and the Y axis follow the window.
+// The image must support animations, like png, which writes an animated
+// png or, using Encoding(png.GIF), an animated gif.
+//
+// Datasets of different lengths move proportionally to the longest one.
+// Frames are sized to the largest frame, set MarginX to keep the chart area
+// in place when the width of the Y axis labels changes.
+func (c *Chart) Timelapse(o *TimelapseOptions) error {
+	anim, ok := c.image.(image.Animator)
+	if !ok {
+		return fmt.Errorf("image does not support animations")
+	}
+	if err := c.query(); err != nil {
+		return err
+	}
+	if len(c.data) == 0 {
+		return fmt.Errorf("no data available")
+	}
+	n := 0
+	for i := range c.data {
+		if l := len(c.data[i].Source()); l > n {
+			n = l
+		}
+	}
+	if o.Window <= 0 || o.Window > n {
+		return fmt.Errorf("window must be between 1 and %d values", n)
+	}
+	step := o.Step
+	if step <= 0 {
+		step = o.Window / 10
+		if step == 0 {
+			step = 1
+		}
+	}
+	fps := o.FPS
+	if fps <= 0 {
+		fps = 10
+	}
+	from := []int{}
+	for i := 0; i+o.Window <= n; i += step {
+		from = append(from, i)
+	}
+	if last := n - o.Window; from[len(from)-1] != last {
+		from = append(from, last)
+	}
+
+	// The first pass sizes the image to the largest frame, so all frames
+	// are the same size.
+	w, h := 0, 0
+	for _, i := range from {
+		_, _, l, err := c.frame(n, i, i+o.Window).setup()
+		if err != nil {
+			return err
+		}
+		if l.Width > w {
+			w = l.Width
+		}
+		if l.Height > h {
+			h = l.Height
+		}
+	}
+	for _, i := range from {
+		f := c.frame(n, i, i+o.Window)
+		mx, my, l, err := f.setup()
+		if err != nil {
+			return err
+		}
+		l.Width, l.Height = w, h
+		if err := f.draw(mx, my, l); err != nil {
+			return err
+		}
+		if err := anim.AddFrame(); err != nil {
+			return err
+		}
+	}
+	return anim.EndAnimation(time.Duration(float64(time.Second) / fps))
+}
+
+// frame returns a copy of the chart showing source values from up to, but
+// not including, to of n values.
+func (c *Chart) frame(n, from, to int) *Chart {
+	f := *c
+	f.data = make(data.Collection, len(c.data))
+	for i := range c.data {
+		m := len(c.data[i].Source())
+		f.data[i] = c.data[i].Window(from*m/n, to*m/n)
+		f.data[i].Resample(f.width)
+	}
+	span := float64(c.end - c.start)
+	f.start = c.start + int64(span*float64(from)/float64(n))
+	f.end = c.start + int64(span*float64(to)/float64(n))
+	return &f
+}